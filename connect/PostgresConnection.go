@@ -2,12 +2,21 @@ package connect
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	cconf "github.com/pip-services3-go/pip-services3-commons-go/config"
 	cerr "github.com/pip-services3-go/pip-services3-commons-go/errors"
 	cref "github.com/pip-services3-go/pip-services3-commons-go/refer"
+	"github.com/pip-services3-go/pip-services3-components-go/auth"
 	clog "github.com/pip-services3-go/pip-services3-components-go/log"
 )
 
@@ -17,13 +26,22 @@ import (
  By defining a connection and sharing it through multiple persistence components
  you can reduce number of used database connections.
 
+ The connection counts its users. Open by an already opened connection only registers
+ a new user and Close releases one, so the pool is closed when the last user closes it.
+ Shutdown closes the pool regardless of the number of users.
+
+ Closing is graceful. New operations are rejected with CONNECTION_CLOSING error, while running
+ operations registered with BeginOperation are given drain_timeout to complete and then canceled.
+
  ### Configuration parameters ###
 
 - connection(s):
   - discovery_key:             (optional) a key to retrieve the connection from IDiscovery
-  - host:                      host name or IP address
-  - port:                      port number (default: 27017)
+  - host:                      host name or IP address, or comma separated list of hosts with optional ports
+  - port:                      port number (default: 5432)
   - uri:                       resource URI or connection string with all parameters in it
+  - role:                      (optional) "primary" (default) or "replica" for read-only replicas
+  - target_session_attrs:      (optional) required session attributes: read-write, read-only, primary, standby, prefer-standby or any
 - credential(s):
   - store_key:                 (optional) a key to retrieve the credentials from ICredentialStore
   - username:                  user name
@@ -31,7 +49,50 @@ import (
 - options:
   - connect_timeout:      (optional) number of milliseconds to wait before timing out when connecting a new client (default: 0)
   - idle_timeout:         (optional) number of milliseconds a client must sit idle in the pool and not be checked out (default: 10000)
-  - max_pool_size:        (optional) maximum number of clients the pool should contain (default: 10)
+  - max_pool_size:        (optional) maximum number of clients the pool should contain (default: 3)
+  - min_pool_size:        (optional) minimum number of clients the pool keeps open (default: 0)
+  - max_conn_lifetime:    (optional) number of milliseconds after which a client is closed and replaced (default: 3600000)
+  - max_conn_lifetime_jitter: (optional) random number of milliseconds added to max_conn_lifetime to avoid closing all clients at once (default: 0)
+  - health_check_period:  (optional) number of milliseconds between health checks of idle clients (default: 60000)
+  - lazy_connect:         (optional) true to postpone connecting to the server until the first client is requested (default: false)
+  - ping_timeout:         (optional) number of milliseconds to wait for a response to health check (default: 5000)
+  - statement_timeout:    (optional) session statement_timeout in milliseconds, 0 disables the timeout
+  - lock_timeout:         (optional) session lock_timeout in milliseconds, 0 disables the timeout
+  - idle_in_transaction_session_timeout: (optional) session idle_in_transaction_session_timeout in milliseconds, 0 disables the timeout
+  - search_path:          (optional) session search_path, for example "myschema,public"
+  - timezone:             (optional) session time zone, for example "UTC"
+  - application_name:     (optional) application name reported in pg_stat_activity
+  - replica_retry_timeout: (optional) number of milliseconds a failed replica is excluded from reading (default: 10000)
+  - refresh_credentials:  (optional) true to resolve credentials from credential store for every new physical connection (default: false)
+  - auto_reconnect:       (optional) true to retry initial connection and restore the pool and replicas when they stop responding (default: false)
+  - reconnect_attempts:   (optional) number of attempts to connect on open when auto_reconnect is enabled, 0 for unlimited (default: 3)
+  - reconnect_delay:      (optional) number of milliseconds before the first reconnection attempt, doubled on every attempt (default: 1000)
+  - reconnect_max_delay:  (optional) maximum number of milliseconds between reconnection attempts (default: 30000)
+  - reconnect_check_interval: (optional) number of milliseconds between checks that the database responds (default: 5000)
+  - failover_check_interval: (optional) number of milliseconds between checks of the server role when target_session_attrs is set (default: 10000)
+  - drain_timeout:        (optional) number of milliseconds to wait for running operations on close before they are canceled (default: 10000)
+
+Connections with role "replica" are opened as separate pools. Read-only operations of persistence
+components are balanced between available replicas and fall back to the primary,
+while writes always use the primary.
+
+When several hosts are set, they are tried in order. The target_session_attrs connection parameter
+selects the required server: read-write, read-only, primary, standby, prefer-standby or any.
+For read-write, primary, read-only and standby the server role is checked periodically
+and the pool is rebuilt against a matching server after failover.
+
+When auto_reconnect is enabled, the connection is supervised in background. After the database
+stops responding the pool is rebuilt with exponential backoff. Persistence components sharing
+the connection pick up the new pool automatically. State changes are logged and reported
+to listeners registered with AddStateListener.
+
+Credentials are applied to every new physical connection in a before-connect hook.
+They are cached and resolved again from the credential store after authentication failure
+of any physical connection, or for every connection when refresh_credentials is true.
+Short-lived passwords, like authentication tokens, can be supplied by a function set with SetPasswordProvider.
+
+Session settings are applied to every new pooled connection right after it is established.
+Additional after-connect callbacks can be registered with AddAfterConnect.
 
 ### References ###
 
@@ -50,8 +111,28 @@ type PostgresConnection struct {
 	Options *cconf.ConfigParams
 	// The PostgreSQL connection pool object.
 	Connection *pgxpool.Pool
+	// The PostgreSQL replica connection pools used for reading.
+	Replicas []*pgxpool.Pool
 	// The PostgreSQL database name.
 	DatabaseName string
+
+	lock               sync.RWMutex
+	openLock           sync.Mutex
+	users              int
+	poolConfig         *pgxpool.Config
+	targetSessionAttrs string
+	cancelMonitor      context.CancelFunc
+	state              PostgresConnectionState
+	credential         *auth.CredentialParams
+	passwordProvider   func(ctx context.Context) (string, error)
+	stateListeners     []func(state PostgresConnectionState, err error)
+	replicaIndex       int
+	replicaFailures    []time.Time
+	afterConnect       []func(ctx context.Context, conn *pgx.Conn) error
+	closing            bool
+	operations         map[int64]context.CancelFunc
+	operationId        int64
+	drained            chan struct{}
 }
 
 // NewPostgresConnection creates a new instance of the connection component.
@@ -61,6 +142,9 @@ func NewPostgresConnection() *PostgresConnection {
 			"options.connect_timeout", 0,
 			"options.idle_timeout", 10000,
 			"options.max_pool_size", 3,
+			"options.ping_timeout", 5000,
+			"options.replica_retry_timeout", 10000,
+			"options.drain_timeout", 10000,
 		),
 		Logger:             clog.NewCompositeLogger(),
 		ConnectionResolver: NewPostgresConnectionResolver(),
@@ -87,73 +171,1083 @@ func (c *PostgresConnection) SetReferences(references cref.IReferences) {
 // Checks if the component is opened.
 // Returns true if the component has been opened and false otherwise.
 func (c *PostgresConnection) IsOpen() bool {
-	return c.Connection != nil
+	return c.GetConnection() != nil
 }
 
 // Opens the component.
 //   - correlationId 	(optional) transaction id to trace execution through call chain.
 //   - Return 			error or nil no errors occured.
 func (c *PostgresConnection) Open(correlationId string) error {
+	c.openLock.Lock()
+	defer c.openLock.Unlock()
+
+	// Already opened connection is shared with the new user
+	if c.GetConnection() != nil {
+		c.users++
+		c.Logger.Trace(correlationId, "Postgres connection is shared by %d users", c.users)
+		return nil
+	}
 
 	uri, err := c.ConnectionResolver.Resolve(correlationId)
 
 	if err != nil {
+		if _, ok := err.(*cerr.ApplicationError); !ok {
+			err = cerr.NewConfigError(correlationId, "RESOLVE_FAILED", "Failed to resolve Postgres connection").
+				WithCauseString(RedactUri(err.Error()))
+		}
 		c.Logger.Error(correlationId, err, "Failed to resolve Postgres connection")
-		return nil
+		return err
 	}
 
-	maxPoolSize := c.Options.GetAsNullableInteger("max_pool_size")
-	idleTimeoutMS := c.Options.GetAsNullableInteger("idle_timeout")
-	connectTimeoutMS := c.Options.GetAsNullableInteger("connect_timeout")
+	tlsConfig, err := c.ConnectionResolver.ResolveTLSConfig(correlationId)
+	if err != nil {
+		c.Logger.Error(correlationId, err, "Failed to resolve Postgres TLS configuration")
+		return err
+	}
 
 	config, err := pgxpool.ParseConfig(uri)
 
 	if err != nil {
+		err = cerr.NewConfigError(correlationId, "INVALID_CONFIG", "Failed to parse Postgres config string").
+			WithCauseString(RedactUri(err.Error()))
 		c.Logger.Error(correlationId, err, "Failed to parse Postgres config string")
-		return nil
+		return err
+	}
+
+	err = c.configureConfig(correlationId, config, tlsConfig)
+	if err != nil {
+		return err
+	}
+
+	targetSessionAttrs, err := c.ConnectionResolver.ResolveTargetSessionAttrs(correlationId)
+	if err != nil {
+		return err
 	}
 
-	if connectTimeoutMS != nil && *connectTimeoutMS != 0 {
-		config.ConnConfig.ConnectTimeout = time.Duration((int64)(*connectTimeoutMS)) * time.Millisecond
+	replicaUris, err := c.ConnectionResolver.ResolveReplicas(correlationId)
+	if err != nil {
+		return err
+	}
+	replicaConfigs := make([]*pgxpool.Config, 0, len(replicaUris))
+	for _, replicaUri := range replicaUris {
+		replicaConfig, err := pgxpool.ParseConfig(replicaUri)
+		if err != nil {
+			return cerr.NewConfigError(correlationId, "INVALID_REPLICA", "Failed to parse Postgres replica config").
+				WithCauseString(RedactUri(err.Error()))
+		}
+		err = c.configureConfig(correlationId, replicaConfig, tlsConfig)
+		if err != nil {
+			return err
+		}
+		// Unavailable replicas must not prevent opening, reads fall back to the primary
+		replicaConfig.LazyConnect = true
+		replicaConfigs = append(replicaConfigs, replicaConfig)
 	}
 
-	c.Logger.Debug(correlationId, "Connecting to postgres")
+	c.Logger.Debug(correlationId, "Connecting to postgres %s", composeTarget(config.ConnConfig))
 
-	pool, err := pgxpool.ConnectConfig(context.Background(), config)
+	pool, err := c.connectWithRetries(correlationId, config)
+	if err != nil {
+		return err
+	}
+
+	replicas := make([]*pgxpool.Pool, 0, len(replicaConfigs))
+	for _, replicaConfig := range replicaConfigs {
+		replica, err := pgxpool.ConnectConfig(context.Background(), replicaConfig)
+		if err != nil {
+			for _, replica := range replicas {
+				replica.Close()
+			}
+			pool.Close()
+			err = cerr.NewConnectionError(correlationId, "CONNECT_FAILED", "Connection to postgres replica failed").
+				WithCauseString(RedactUri(err.Error()))
+			c.setState(correlationId, PostgresConnectionFailed, err)
+			return err
+		}
+		replicas = append(replicas, replica)
+	}
+	if len(replicas) > 0 {
+		c.Logger.Debug(correlationId, "Using %d postgres replicas for reading", len(replicas))
+	}
+
+	c.lock.Lock()
+	c.Connection = pool
+	c.Replicas = replicas
+	c.replicaFailures = make([]time.Time, len(replicas))
+	c.poolConfig = config
+	c.DatabaseName = config.ConnConfig.Database
+	c.targetSessionAttrs = targetSessionAttrs
+	c.lock.Unlock()
+
+	c.users = 1
+	c.setState(correlationId, PostgresConnectionConnected, nil)
+	c.startSupervisor(correlationId)
+	return nil
+}
+
+// Connects to the database. When auto reconnect is enabled, failed attempts
+// are repeated with exponential backoff up to the configured number of attempts.
+func (c *PostgresConnection) connectWithRetries(correlationId string, config *pgxpool.Config) (*pgxpool.Pool, error) {
+	autoReconnect := c.Options.GetAsBooleanWithDefault("auto_reconnect", false)
+	attempts := c.Options.GetAsIntegerWithDefault("reconnect_attempts", 3)
+	if !autoReconnect {
+		attempts = 1
+	}
+
+	c.setState(correlationId, PostgresConnectionConnecting, nil)
+
+	var err error
+	for attempt := 1; attempts <= 0 || attempt <= attempts; attempt++ {
+		var pool *pgxpool.Pool
+		pool, err = pgxpool.ConnectConfig(context.Background(), config.Copy())
+		if err == nil {
+			return pool, nil
+		}
+		c.checkAuthError(correlationId, err)
+		if attempts > 0 && attempt == attempts {
+			break
+		}
 
-	if err != nil || pool == nil {
-		err = cerr.NewConnectionError(correlationId, "CONNECT_FAILED", "Connection to postgres failed").WithCause(err)
+		delay := c.getReconnectDelay(attempt)
+		c.Logger.Warn(correlationId, "Connection to postgres failed (attempt %d), retrying in %v: %s", attempt, delay, RedactUri(err.Error()))
+		time.Sleep(delay)
+	}
+
+	err = cerr.NewConnectionError(correlationId, "CONNECT_FAILED", "Connection to postgres failed").
+		WithCauseString(RedactUri(err.Error()))
+	c.setState(correlationId, PostgresConnectionFailed, err)
+	return nil, err
+}
+
+// Calculates exponential backoff delay for the reconnection attempt
+func (c *PostgresConnection) getReconnectDelay(attempt int) time.Duration {
+	delay := c.Options.GetAsIntegerWithDefault("reconnect_delay", 1000)
+	maxDelay := c.Options.GetAsIntegerWithDefault("reconnect_max_delay", 30000)
+	if delay <= 0 {
+		delay = 1000
+	}
+
+	result := time.Duration(delay) * time.Millisecond
+	for i := 1; i < attempt; i++ {
+		result *= 2
+		if maxDelay > 0 && result >= time.Duration(maxDelay)*time.Millisecond {
+			return time.Duration(maxDelay) * time.Millisecond
+		}
+	}
+	return result
+}
+
+// Registers a listener that is notified about connection state changes.
+// Listeners are called synchronously and shall not block.
+//   - listener 	a function called with a new state and an error that caused it.
+func (c *PostgresConnection) AddStateListener(listener func(state PostgresConnectionState, err error)) {
+	if listener == nil {
+		return
+	}
+	c.lock.Lock()
+	c.stateListeners = append(c.stateListeners, listener)
+	c.lock.Unlock()
+}
+
+// Gets the current connection state.
+func (c *PostgresConnection) GetState() PostgresConnectionState {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if c.state == "" {
+		return PostgresConnectionClosed
+	}
+	return c.state
+}
+
+func (c *PostgresConnection) setState(correlationId string, state PostgresConnectionState, err error) {
+	c.lock.Lock()
+	changed := c.state != state
+	c.state = state
+	listeners := append([]func(state PostgresConnectionState, err error){}, c.stateListeners...)
+	c.lock.Unlock()
+
+	if !changed {
+		return
+	}
+
+	if err != nil {
+		c.Logger.Warn(correlationId, "Postgres connection state changed to %s: %s", state, RedactUri(err.Error()))
 	} else {
-		if idleTimeoutMS != nil && *idleTimeoutMS != 0 {
-			pool.Config().MaxConnIdleTime = time.Duration((int64)(*idleTimeoutMS)) * time.Millisecond
+		c.Logger.Info(correlationId, "Postgres connection state changed to %s", state)
+	}
+	for _, listener := range listeners {
+		listener(state, err)
+	}
+}
+
+// Applies TLS, pool and session options to the pool configuration.
+func (c *PostgresConnection) configureConfig(correlationId string, config *pgxpool.Config, tlsConfig *tls.Config) error {
+	if tlsConfig != nil {
+		c.configureTLS(config.ConnConfig, tlsConfig)
+	}
+
+	err := c.configurePool(correlationId, config)
+	if err != nil {
+		return err
+	}
+
+	c.configureCredentials(correlationId, config)
+
+	return c.configureSession(correlationId, config)
+}
+
+// Sets a function that provides a password for every new physical connection.
+// It is intended for short-lived authentication tokens and takes precedence over
+// the password from credentials. The provider must be set before the connection is opened.
+//   - provider 	a function that returns a password or error.
+func (c *PostgresConnection) SetPasswordProvider(provider func(ctx context.Context) (string, error)) {
+	c.lock.Lock()
+	c.passwordProvider = provider
+	c.lock.Unlock()
+}
+
+// Sets before-connect hook that applies current credentials to every new physical connection.
+func (c *PostgresConnection) configureCredentials(correlationId string, config *pgxpool.Config) {
+	refresh := c.Options.GetAsBooleanWithDefault("refresh_credentials", false)
+
+	c.lock.RLock()
+	provider := c.passwordProvider
+	c.lock.RUnlock()
+
+	prevBeforeConnect := config.BeforeConnect
+	config.BeforeConnect = func(ctx context.Context, connConfig *pgx.ConnConfig) error {
+		if prevBeforeConnect != nil {
+			if err := prevBeforeConnect(ctx, connConfig); err != nil {
+				return err
+			}
 		}
-		if maxPoolSize != nil && *maxPoolSize != 0 {
-			pool.Config().MaxConns = (int32)(*maxPoolSize)
+
+		credential, err := c.lookupCredential(correlationId, refresh)
+		if err != nil {
+			return cerr.NewConnectionError(correlationId, "CREDENTIAL_LOOKUP_FAILED", "Failed to resolve postgres credentials").
+				WithCause(err)
+		}
+		if credential != nil {
+			if username := credential.Username(); username != "" {
+				connConfig.User = username
+			}
+			if password := credential.Password(); password != "" {
+				connConfig.Password = password
+			}
 		}
-		c.Connection = pool
-		c.DatabaseName = config.ConnConfig.Database
+
+		if provider != nil {
+			password, err := provider(ctx)
+			if err != nil {
+				return cerr.NewConnectionError(correlationId, "PASSWORD_PROVIDER_FAILED", "Failed to get postgres password").
+					WithCause(err)
+			}
+			connConfig.Password = password
+		}
+
+		// Authentication errors of pooled connections are not returned to the connection,
+		// so they are detected in messages received from the server
+		buildFrontend := connConfig.BuildFrontend
+		connConfig.BuildFrontend = func(r io.Reader, w io.Writer) pgconn.Frontend {
+			return &authCheckFrontend{
+				Frontend: buildFrontend(r, w),
+				onAuthError: func() {
+					c.invalidateCredential(correlationId)
+				},
+			}
+		}
+		return nil
 	}
-	return err
+}
+
+// Frontend that reports authentication errors received from the server
+type authCheckFrontend struct {
+	pgconn.Frontend
+	onAuthError func()
+}
+
+func (f *authCheckFrontend) Receive() (pgproto3.BackendMessage, error) {
+	msg, err := f.Frontend.Receive()
+	if errMsg, ok := msg.(*pgproto3.ErrorResponse); ok && isAuthErrorCode(errMsg.Code) {
+		f.onAuthError()
+	}
+	return msg, err
+}
+
+// Gets credentials for a new physical connection. Cached credentials are used
+// unless refresh is requested or they were invalidated after authentication failure.
+func (c *PostgresConnection) lookupCredential(correlationId string, refresh bool) (*auth.CredentialParams, error) {
+	c.lock.RLock()
+	credential := c.credential
+	c.lock.RUnlock()
+
+	if credential != nil && !refresh {
+		return credential, nil
+	}
+
+	credential, err := c.ConnectionResolver.CredentialResolver.Lookup(correlationId)
+	if err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	c.credential = credential
+	c.lock.Unlock()
+	return credential, nil
+}
+
+// Checks if the error is caused by authentication failure and invalidates cached credentials,
+// so they are resolved again from credential store for the next physical connection.
+func (c *PostgresConnection) checkAuthError(correlationId string, err error) {
+	var pgErr *pgconn.PgError
+	if err == nil || !errors.As(err, &pgErr) {
+		return
+	}
+	if isAuthErrorCode(pgErr.Code) {
+		c.invalidateCredential(correlationId)
+	}
+}
+
+// Invalidates cached credentials, so they are resolved again from credential store for the next physical connection.
+func (c *PostgresConnection) invalidateCredential(correlationId string) {
+	c.lock.Lock()
+	cached := c.credential != nil
+	c.credential = nil
+	c.lock.Unlock()
+
+	if cached {
+		c.Logger.Warn(correlationId, "Postgres authentication failed, credentials will be resolved again")
+	}
+}
+
+// Checks if the SQLSTATE code means invalid password or invalid authorization
+func isAuthErrorCode(code string) bool {
+	return code == "28P01" || code == "28000"
+}
+
+// Replaces TLS configuration created by the driver for every host that uses SSL.
+func (c *PostgresConnection) configureTLS(config *pgx.ConnConfig, tlsConfig *tls.Config) {
+	hostTLSConfig := func(host string) *tls.Config {
+		result := tlsConfig.Clone()
+		if !result.InsecureSkipVerify && result.ServerName == "" {
+			result.ServerName = host
+		}
+		return result
+	}
+
+	if config.TLSConfig != nil {
+		config.TLSConfig = hostTLSConfig(config.Host)
+	}
+	for _, fallback := range config.Fallbacks {
+		if fallback.TLSConfig != nil {
+			fallback.TLSConfig = hostTLSConfig(fallback.Host)
+		}
+	}
+}
+
+// Applies pool options to the pool configuration before connecting.
+func (c *PostgresConnection) configurePool(correlationId string, config *pgxpool.Config) error {
+	connectTimeoutMS := c.Options.GetAsIntegerWithDefault("connect_timeout", 0)
+	maxPoolSize := c.Options.GetAsIntegerWithDefault("max_pool_size", 0)
+	minPoolSize := c.Options.GetAsIntegerWithDefault("min_pool_size", 0)
+	idleTimeoutMS := c.Options.GetAsIntegerWithDefault("idle_timeout", 0)
+	maxLifetimeMS := c.Options.GetAsIntegerWithDefault("max_conn_lifetime", 0)
+	maxLifetimeJitterMS := c.Options.GetAsIntegerWithDefault("max_conn_lifetime_jitter", 0)
+	healthCheckPeriodMS := c.Options.GetAsIntegerWithDefault("health_check_period", 0)
+	lazyConnect := c.Options.GetAsBooleanWithDefault("lazy_connect", false)
+
+	options := map[string]int{
+		"connect_timeout":          connectTimeoutMS,
+		"max_pool_size":            maxPoolSize,
+		"min_pool_size":            minPoolSize,
+		"idle_timeout":             idleTimeoutMS,
+		"max_conn_lifetime":        maxLifetimeMS,
+		"max_conn_lifetime_jitter": maxLifetimeJitterMS,
+		"health_check_period":      healthCheckPeriodMS,
+	}
+	for name, value := range options {
+		if value < 0 {
+			return cerr.NewConfigError(correlationId, "INVALID_POOL_OPTION", "Pool option "+name+" cannot be negative").
+				WithDetails("option", name).WithDetails("value", value)
+		}
+	}
+
+	if connectTimeoutMS != 0 {
+		config.ConnConfig.ConnectTimeout = time.Duration(connectTimeoutMS) * time.Millisecond
+	}
+	if maxPoolSize != 0 {
+		config.MaxConns = int32(maxPoolSize)
+	}
+	if minPoolSize != 0 {
+		config.MinConns = int32(minPoolSize)
+	}
+	if idleTimeoutMS != 0 {
+		config.MaxConnIdleTime = time.Duration(idleTimeoutMS) * time.Millisecond
+	}
+	if maxLifetimeMS != 0 {
+		config.MaxConnLifetime = time.Duration(maxLifetimeMS) * time.Millisecond
+	}
+	if maxLifetimeJitterMS != 0 {
+		config.MaxConnLifetimeJitter = time.Duration(maxLifetimeJitterMS) * time.Millisecond
+	}
+	if healthCheckPeriodMS != 0 {
+		config.HealthCheckPeriod = time.Duration(healthCheckPeriodMS) * time.Millisecond
+	}
+	config.LazyConnect = lazyConnect
+
+	if config.MinConns > config.MaxConns {
+		return cerr.NewConfigError(correlationId, "INVALID_POOL_SIZE", "Pool option min_pool_size cannot be greater than max_pool_size").
+			WithDetails("min_pool_size", config.MinConns).WithDetails("max_pool_size", config.MaxConns)
+	}
+
+	c.Logger.Debug(correlationId, "Postgres pool options: min_pool_size=%d, max_pool_size=%d, idle_timeout=%v, "+
+		"max_conn_lifetime=%v, max_conn_lifetime_jitter=%v, health_check_period=%v, connect_timeout=%v, lazy_connect=%t",
+		config.MinConns, config.MaxConns, config.MaxConnIdleTime, config.MaxConnLifetime, config.MaxConnLifetimeJitter,
+		config.HealthCheckPeriod, config.ConnConfig.ConnectTimeout, config.LazyConnect)
+
+	return nil
+}
+
+// Registers a callback that is called on every new pooled connection
+// after session settings are applied. It can be used, for instance, to register custom types.
+// Callbacks must be added before the connection is opened.
+//   - callback 	a function to be called with a newly established connection.
+func (c *PostgresConnection) AddAfterConnect(callback func(ctx context.Context, conn *pgx.Conn) error) {
+	if callback != nil {
+		c.afterConnect = append(c.afterConnect, callback)
+	}
+}
+
+// Sets after-connect hook that applies session settings and calls registered callbacks.
+func (c *PostgresConnection) configureSession(correlationId string, config *pgxpool.Config) error {
+	settings := make([][2]string, 0)
+
+	timeouts := []string{"statement_timeout", "lock_timeout", "idle_in_transaction_session_timeout"}
+	for _, name := range timeouts {
+		if c.Options.GetAsNullableString(name) == nil {
+			continue
+		}
+		value := c.Options.GetAsNullableInteger(name)
+		if value == nil || *value < 0 {
+			return cerr.NewConfigError(correlationId, "INVALID_SESSION_OPTION", "Session option "+name+" must be a non-negative number of milliseconds").
+				WithDetails("option", name).WithDetails("value", c.Options.GetAsString(name))
+		}
+		settings = append(settings, [2]string{name, strconv.Itoa(*value)})
+	}
+
+	names := []string{"search_path", "timezone", "application_name"}
+	for _, name := range names {
+		value := c.Options.GetAsString(name)
+		if value != "" {
+			settings = append(settings, [2]string{name, value})
+		}
+	}
+
+	if len(settings) == 0 && len(c.afterConnect) == 0 {
+		return nil
+	}
+
+	for _, setting := range settings {
+		c.Logger.Debug(correlationId, "Postgres session setting %s=%s", setting[0], setting[1])
+	}
+
+	callbacks := append([]func(ctx context.Context, conn *pgx.Conn) error{}, c.afterConnect...)
+	prevAfterConnect := config.AfterConnect
+	config.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		if prevAfterConnect != nil {
+			if err := prevAfterConnect(ctx, conn); err != nil {
+				return err
+			}
+		}
+		for _, setting := range settings {
+			_, err := conn.Exec(ctx, "SELECT set_config($1, $2, false)", setting[0], setting[1])
+			if err != nil {
+				return cerr.NewConnectionError(correlationId, "SESSION_SETTING_FAILED", "Failed to set session setting "+setting[0]).
+					WithCause(err)
+			}
+		}
+		for _, callback := range callbacks {
+			if err := callback(ctx, conn); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return nil
 }
 
 // Closes component and frees used resources.
+// The connection can be opened by several users, for instance persistence components sharing it.
+// Every call releases one user and the pool is closed only when the last user closes the connection.
 //   - correlationId 	(optional) transaction id to trace execution through call chain.
 // Return			 error or nil no errors occured
 func (c *PostgresConnection) Close(correlationId string) error {
-	if c.Connection == nil {
-		return nil
+	_, err := c.CloseWithResult(correlationId)
+	return err
+}
+
+// Closes component like Close and reports operations aborted on close.
+// Operations running on close are waited for up to drain timeout and then canceled.
+//   - correlationId 	(optional) transaction id to trace execution through call chain.
+// Returns the number of canceled operations and error or nil no errors occured
+func (c *PostgresConnection) CloseWithResult(correlationId string) (aborted int, err error) {
+	c.openLock.Lock()
+	defer c.openLock.Unlock()
+
+	if c.users > 1 {
+		c.users--
+		c.Logger.Trace(correlationId, "Postgres connection is still used by %d users", c.users)
+		return 0, nil
 	}
-	c.Connection.Close()
-	c.Logger.Debug(correlationId, "Disconnected from postgres database %s", c.DatabaseName)
+	return c.shutdown(correlationId)
+}
+
+// Closes the connection regardless of the number of users.
+// Persistence components using the connection get an error on the next operation.
+//   - correlationId 	(optional) transaction id to trace execution through call chain.
+// Returns the number of operations canceled on close and error or nil no errors occured
+func (c *PostgresConnection) Shutdown(correlationId string) (aborted int, err error) {
+	c.openLock.Lock()
+	defer c.openLock.Unlock()
+
+	if c.users > 1 {
+		c.Logger.Warn(correlationId, "Shutting down postgres connection used by %d users", c.users)
+	}
+	return c.shutdown(correlationId)
+}
+
+// Gets the number of users that opened the connection and have not closed it yet.
+func (c *PostgresConnection) GetUsersCount() int {
+	c.openLock.Lock()
+	defer c.openLock.Unlock()
+	return c.users
+}
+
+func (c *PostgresConnection) shutdown(correlationId string) (aborted int, err error) {
+	c.users = 0
+
+	if c.GetConnection() != nil {
+		if aborted, err = c.Drain(correlationId); err != nil {
+			return 0, err
+		}
+	}
+
+	c.lock.Lock()
+	pool := c.Connection
+	replicas := c.Replicas
+	cancelMonitor := c.cancelMonitor
 	c.Connection = nil
+	c.Replicas = nil
+	c.replicaFailures = nil
+	c.poolConfig = nil
+	c.cancelMonitor = nil
+	c.credential = nil
+	c.closing = false
+	databaseName := c.DatabaseName
 	c.DatabaseName = ""
+	c.lock.Unlock()
+
+	if pool == nil {
+		return 0, nil
+	}
+	if cancelMonitor != nil {
+		cancelMonitor()
+	}
+	for _, replica := range replicas {
+		replica.Close()
+	}
+	pool.Close()
+	c.Logger.Debug(correlationId, "Disconnected from postgres database %s", databaseName)
+	c.setState(correlationId, PostgresConnectionClosed, nil)
+	return aborted, nil
+}
+
+// Registers an operation that uses the connection, like a query or a transaction,
+// so it can be waited for or canceled on close.
+//   - ctx 			operation context.
+//   - correlationId 	(optional) transaction id to trace execution through call chain.
+// Returns operation context that is canceled when the operation does not complete within drain timeout,
+// a function that must be called when the operation completes,
+// and error if the connection is not opened or is closing.
+func (c *PostgresConnection) BeginOperation(ctx context.Context, correlationId string) (context.Context, func(), error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.Connection == nil {
+		return nil, nil, cerr.NewInvalidStateError(correlationId, "NOT_OPENED", "Postgres connection is not opened")
+	}
+	if c.closing {
+		return nil, nil, cerr.NewInvalidStateError(correlationId, "CONNECTION_CLOSING",
+			"Postgres connection is closing and does not accept new operations")
+	}
+
+	if c.operations == nil {
+		c.operations = make(map[int64]context.CancelFunc)
+	}
+	c.operationId++
+	id := c.operationId
+	ctx, cancel := context.WithCancel(ctx)
+	c.operations[id] = cancel
+
+	once := sync.Once{}
+	done := func() {
+		once.Do(func() {
+			cancel()
+			c.lock.Lock()
+			defer c.lock.Unlock()
+			delete(c.operations, id)
+			if len(c.operations) == 0 && c.drained != nil {
+				close(c.drained)
+				c.drained = nil
+			}
+		})
+	}
+	return ctx, done, nil
+}
+
+// Gets the number of running operations registered with BeginOperation.
+func (c *PostgresConnection) GetOperationsCount() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return len(c.operations)
+}
+
+// Stops accepting new operations and waits for running operations to complete
+// within drain timeout. Operations that did not complete in time are canceled.
+// It is called on close, but can also be called before close to get the number of aborted operations.
+//   - correlationId 	(optional) transaction id to trace execution through call chain.
+// Returns the number of canceled operations and error if the connection is not opened.
+func (c *PostgresConnection) Drain(correlationId string) (aborted int, err error) {
+	timeout := c.Options.GetAsIntegerWithDefault("drain_timeout", 10000)
+
+	c.lock.Lock()
+	if c.Connection == nil {
+		c.lock.Unlock()
+		return 0, cerr.NewInvalidStateError(correlationId, "NOT_OPENED", "Postgres connection is not opened")
+	}
+	c.closing = true
+	count := len(c.operations)
+	if count == 0 {
+		c.lock.Unlock()
+		return 0, nil
+	}
+	if c.drained == nil {
+		c.drained = make(chan struct{})
+	}
+	drained := c.drained
+	c.lock.Unlock()
+
+	c.Logger.Info(correlationId, "Waiting up to %d ms for %d postgres operations to complete", timeout, count)
+
+	timer := time.NewTimer(time.Duration(timeout) * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case <-drained:
+		return 0, nil
+	case <-timer.C:
+	}
+
+	c.lock.Lock()
+	cancels := make([]context.CancelFunc, 0, len(c.operations))
+	for _, cancel := range c.operations {
+		cancels = append(cancels, cancel)
+	}
+	c.lock.Unlock()
+
+	for _, cancel := range cancels {
+		cancel()
+	}
+	aborted = len(cancels)
+	if aborted > 0 {
+		c.Logger.Warn(correlationId, "Canceled %d postgres operations not completed within %d ms", aborted, timeout)
+	}
+	return aborted, nil
+}
+
+// Pings the database server to check if it is reachable.
+//   - correlationId 	(optional) transaction id to trace execution through call chain.
+// Return			 error or nil if the server responded within ping timeout
+func (c *PostgresConnection) Ping(correlationId string) error {
+	pool := c.GetConnection()
+	if pool == nil {
+		return cerr.NewInvalidStateError(correlationId, "NOT_OPENED", "Postgres connection is not opened")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.getPingTimeout())
+	defer cancel()
+
+	err := pool.Ping(ctx)
+	if err != nil {
+		c.checkAuthError(correlationId, err)
+		return cerr.NewConnectionError(correlationId, "PING_FAILED", "Postgres database does not respond").
+			WithCauseString(RedactUri(err.Error()))
+	}
 	return nil
 }
 
+// Checks health of the database connection. It pings the server within ping timeout,
+// measures the latency, reads server version and recovery state and collects pool statistics.
+//   - correlationId 	(optional) transaction id to trace execution through call chain.
+// Returns health status and error if the database is not healthy.
+// The status is returned in both cases and can be serialized by status endpoints.
+func (c *PostgresConnection) CheckHealth(correlationId string) (status *PostgresHealthStatus, err error) {
+	status = &PostgresHealthStatus{
+		CheckedAt: time.Now().UTC(),
+	}
+
+	pool := c.GetConnection()
+	if pool == nil {
+		err = cerr.NewInvalidStateError(correlationId, "NOT_OPENED", "Postgres connection is not opened")
+		status.Error = err.Error()
+		return status, err
+	}
+
+	err = c.checkPoolHealth(correlationId, pool, status)
+
+	c.lock.RLock()
+	replicas := append([]*pgxpool.Pool{}, c.Replicas...)
+	c.lock.RUnlock()
+	for _, replica := range replicas {
+		replicaStatus := &PostgresHealthStatus{CheckedAt: status.CheckedAt}
+		c.checkPoolHealth(correlationId, replica, replicaStatus)
+		status.Replicas = append(status.Replicas, replicaStatus)
+	}
+
+	return status, err
+}
+
+// Checks health of a single connection pool and fills the status
+func (c *PostgresConnection) checkPoolHealth(correlationId string, pool *pgxpool.Pool, status *PostgresHealthStatus) (err error) {
+	stat := pool.Stat()
+	status.TotalConns = stat.TotalConns()
+	status.AcquiredConns = stat.AcquiredConns()
+	status.IdleConns = stat.IdleConns()
+	status.MaxConns = stat.MaxConns()
+	if status.MaxConns > 0 {
+		status.Saturation = float64(status.AcquiredConns) / float64(status.MaxConns)
+	}
+	host := pool.Config().ConnConfig.Host
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.getPingTimeout())
+	defer cancel()
+
+	start := time.Now()
+	row := pool.QueryRow(ctx, "SELECT current_setting('server_version'), pg_is_in_recovery()")
+	qErr := row.Scan(&status.ServerVersion, &status.InRecovery)
+	status.LatencyMs = time.Since(start).Milliseconds()
+
+	if qErr != nil {
+		err = cerr.NewConnectionError(correlationId, "PING_FAILED", "Postgres database does not respond").
+			WithCauseString(RedactUri(qErr.Error()))
+		status.Error = err.Error()
+		c.Logger.Warn(correlationId, "Health check of postgres database %s at %s failed: %s", c.DatabaseName, host, RedactUri(qErr.Error()))
+		return err
+	}
+
+	status.Healthy = true
+	c.Logger.Trace(correlationId, "Health check of postgres database %s at %s passed in %d ms", c.DatabaseName, host, status.LatencyMs)
+	return nil
+}
+
+func (c *PostgresConnection) getPingTimeout() time.Duration {
+	timeout := c.Options.GetAsIntegerWithDefault("ping_timeout", 5000)
+	if timeout <= 0 {
+		timeout = 5000
+	}
+	return time.Duration(timeout) * time.Millisecond
+}
+
+// Gets the current connection pool to the primary server.
+// The pool can be replaced after failover, so it shall not be cached for a long time.
 func (c *PostgresConnection) GetConnection() *pgxpool.Pool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
 	return c.Connection
 }
 
+// Gets a connection pool for read-only operations.
+// It balances requests between replicas that have not failed recently
+// and falls back to the primary when no replicas are available.
+// Returns a replica or primary connection pool.
+func (c *PostgresConnection) GetReadConnection() *pgxpool.Pool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now()
+	count := len(c.Replicas)
+	for i := 0; i < count; i++ {
+		index := (c.replicaIndex + i) % count
+		if c.replicaFailures[index].Before(now) {
+			c.replicaIndex = (index + 1) % count
+			return c.Replicas[index]
+		}
+	}
+	return c.Connection
+}
+
+// Excludes a replica from reading for replica retry timeout after it failed to respond.
+//   - correlationId 	(optional) transaction id to trace execution through call chain.
+//   - replica 		a replica connection pool that failed.
+func (c *PostgresConnection) MarkReplicaFailed(correlationId string, replica *pgxpool.Pool) {
+	timeout := c.Options.GetAsIntegerWithDefault("replica_retry_timeout", 10000)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for index, pool := range c.Replicas {
+		if pool == replica {
+			c.replicaFailures[index] = time.Now().Add(time.Duration(timeout) * time.Millisecond)
+			c.Logger.Warn(correlationId, "Postgres replica %s:%d failed and is excluded for %d ms",
+				pool.Config().ConnConfig.Host, pool.Config().ConnConfig.Port, timeout)
+		}
+	}
+}
+
 func (c *PostgresConnection) GetDatabaseName() string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
 	return c.DatabaseName
 }
+
+// Starts background supervision of the connection. It restores the pool when the database
+// stops responding and auto_reconnect is enabled, and detects failover when
+// target_session_attrs requires a specific server role.
+func (c *PostgresConnection) startSupervisor(correlationId string) {
+	autoReconnect := c.Options.GetAsBooleanWithDefault("auto_reconnect", false)
+	reconnectInterval := c.Options.GetAsIntegerWithDefault("reconnect_check_interval", 5000)
+	failoverInterval := c.Options.GetAsIntegerWithDefault("failover_check_interval", 10000)
+
+	c.lock.RLock()
+	targetSessionAttrs := c.targetSessionAttrs
+	c.lock.RUnlock()
+
+	detectFailover := failoverInterval > 0
+	switch targetSessionAttrs {
+	case "read-write", "primary", "read-only", "standby":
+	default:
+		detectFailover = false
+	}
+	reconnect := autoReconnect && reconnectInterval > 0
+
+	if !detectFailover && !reconnect {
+		return
+	}
+
+	interval := reconnectInterval
+	if !reconnect || (detectFailover && failoverInterval < interval) {
+		interval = failoverInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.lock.Lock()
+	c.cancelMonitor = cancel
+	c.lock.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(interval) * time.Millisecond)
+		defer ticker.Stop()
+		lastFailoverCheck := time.Now()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if reconnect {
+				if err := c.Ping(correlationId); err != nil {
+					c.recover(ctx, correlationId, err)
+					continue
+				}
+				c.recoverReplicas(correlationId)
+			}
+
+			if detectFailover && time.Since(lastFailoverCheck) >= time.Duration(failoverInterval)*time.Millisecond {
+				lastFailoverCheck = time.Now()
+				if _, err := c.DetectFailover(correlationId); err != nil {
+					c.Logger.Warn(correlationId, "Failed to check postgres server role: %s", RedactUri(err.Error()))
+				}
+			}
+		}
+	}()
+}
+
+// Rebuilds the pool with exponential backoff until it succeeds or the connection is closed.
+func (c *PostgresConnection) recover(ctx context.Context, correlationId string, cause error) {
+	c.setState(correlationId, PostgresConnectionReconnecting, cause)
+
+	for attempt := 1; ; attempt++ {
+		delay := c.getReconnectDelay(attempt)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		c.lock.RLock()
+		pool := c.Connection
+		c.lock.RUnlock()
+		if pool == nil {
+			return
+		}
+
+		err := c.rebuildPool(correlationId, pool)
+		if err == nil {
+			err = c.Ping(correlationId)
+		}
+		if err == nil {
+			c.setState(correlationId, PostgresConnectionConnected, nil)
+			return
+		}
+		c.Logger.Warn(correlationId, "Reconnection to postgres failed (attempt %d): %s", attempt, RedactUri(err.Error()))
+	}
+}
+
+// Checks replicas and rebuilds the ones that do not respond, so reads return to them
+// when they are available again. Rebuilt replicas stay excluded until they respond.
+func (c *PostgresConnection) recoverReplicas(correlationId string) {
+	c.lock.RLock()
+	replicas := append([]*pgxpool.Pool{}, c.Replicas...)
+	c.lock.RUnlock()
+
+	for index, replica := range replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), c.getPingTimeout())
+		err := replica.Ping(ctx)
+		cancel()
+
+		if err == nil {
+			c.lock.Lock()
+			if index < len(c.Replicas) && c.Replicas[index] == replica && !c.replicaFailures[index].IsZero() {
+				c.replicaFailures[index] = time.Time{}
+				c.Logger.Info(correlationId, "Postgres replica %s:%d is available again",
+					replica.Config().ConnConfig.Host, replica.Config().ConnConfig.Port)
+			}
+			c.lock.Unlock()
+			continue
+		}
+
+		c.MarkReplicaFailed(correlationId, replica)
+		if err = c.rebuildReplica(correlationId, index, replica); err != nil {
+			c.Logger.Warn(correlationId, "Reconnection to postgres replica failed: %s", RedactUri(err.Error()))
+		}
+	}
+}
+
+// Replaces the replica connection pool with a new one created from the same configuration.
+// The old pool is closed in background after all acquired connections are released.
+func (c *PostgresConnection) rebuildReplica(correlationId string, index int, oldReplica *pgxpool.Pool) error {
+	replica, err := pgxpool.ConnectConfig(context.Background(), oldReplica.Config())
+	if err != nil {
+		c.checkAuthError(correlationId, err)
+		return cerr.NewConnectionError(correlationId, "CONNECT_FAILED", "Reconnection to postgres replica failed").
+			WithCauseString(RedactUri(err.Error()))
+	}
+
+	c.lock.Lock()
+	if index >= len(c.Replicas) || c.Replicas[index] != oldReplica {
+		// The connection was closed or rebuilt concurrently
+		c.lock.Unlock()
+		replica.Close()
+		return nil
+	}
+	c.Replicas[index] = replica
+	c.lock.Unlock()
+
+	go oldReplica.Close()
+	return nil
+}
+
+// Detects failover by checking if the connected server still has the role
+// required by target_session_attrs. When the role has changed, for instance the primary
+// was demoted to a standby, the pool is rebuilt against a server with the required role.
+//   - correlationId 	(optional) transaction id to trace execution through call chain.
+// Returns true if failover was detected and the pool was rebuilt, and error if the check failed.
+func (c *PostgresConnection) DetectFailover(correlationId string) (bool, error) {
+	c.lock.RLock()
+	pool := c.Connection
+	targetSessionAttrs := c.targetSessionAttrs
+	c.lock.RUnlock()
+
+	if pool == nil {
+		return false, cerr.NewInvalidStateError(correlationId, "NOT_OPENED", "Postgres connection is not opened")
+	}
+
+	var wantRecovery bool
+	switch targetSessionAttrs {
+	case "read-write", "primary":
+		wantRecovery = false
+	case "read-only", "standby":
+		wantRecovery = true
+	default:
+		return false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.getPingTimeout())
+	defer cancel()
+
+	var inRecovery bool
+	err := pool.QueryRow(ctx, "SELECT pg_is_in_recovery()").Scan(&inRecovery)
+	if err != nil {
+		return false, cerr.NewConnectionError(correlationId, "PING_FAILED", "Postgres database does not respond").
+			WithCauseString(RedactUri(err.Error()))
+	}
+	if inRecovery == wantRecovery {
+		return false, nil
+	}
+
+	c.Logger.Warn(correlationId, "Postgres failover detected: server %s no longer matches target_session_attrs=%s",
+		pool.Config().ConnConfig.Host, targetSessionAttrs)
+
+	err = c.rebuildPool(correlationId, pool)
+	if err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+// Replaces the primary connection pool with a new one created from the same configuration.
+// The old pool is closed in background after all acquired connections are released.
+func (c *PostgresConnection) rebuildPool(correlationId string, oldPool *pgxpool.Pool) error {
+	c.lock.RLock()
+	config := c.poolConfig
+	c.lock.RUnlock()
+
+	if config == nil {
+		return cerr.NewInvalidStateError(correlationId, "NOT_OPENED", "Postgres connection is not opened")
+	}
+
+	pool, err := pgxpool.ConnectConfig(context.Background(), config.Copy())
+	if err != nil {
+		c.checkAuthError(correlationId, err)
+		return cerr.NewConnectionError(correlationId, "CONNECT_FAILED", "Reconnection to postgres failed").
+			WithCauseString(RedactUri(err.Error()))
+	}
+
+	c.lock.Lock()
+	if c.Connection != oldPool {
+		// The connection was closed or rebuilt concurrently
+		c.lock.Unlock()
+		pool.Close()
+		return nil
+	}
+	c.Connection = pool
+	c.lock.Unlock()
+
+	go oldPool.Close()
+
+	c.Logger.Info(correlationId, "Reconnected to postgres database %s", c.DatabaseName)
+	return nil
+}
+
+// Composes a description of the connection target for logging.
+// It includes only hosts, ports, database and user, without password and other parameters.
+func composeTarget(config *pgx.ConnConfig) string {
+	hosts := config.Host + ":" + strconv.Itoa(int(config.Port))
+	for _, fallback := range config.Fallbacks {
+		if fallback.Host == config.Host && fallback.Port == config.Port {
+			continue
+		}
+		hosts += "," + fallback.Host + ":" + strconv.Itoa(int(fallback.Port))
+	}
+	return "host=" + hosts + " database=" + config.Database + " user=" + config.User
+}
@@ -1,6 +1,17 @@
 package connect
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/user"
+	"regexp"
+	"strconv"
+	"strings"
+
 	pgx4 "github.com/jackc/pgx/v4"
 	cconf "github.com/pip-services3-go/pip-services3-commons-go/config"
 	cdata "github.com/pip-services3-go/pip-services3-commons-go/data"
@@ -8,8 +19,6 @@ import (
 	crefer "github.com/pip-services3-go/pip-services3-commons-go/refer"
 	"github.com/pip-services3-go/pip-services3-components-go/auth"
 	ccon "github.com/pip-services3-go/pip-services3-components-go/connect"
-	"net/url"
-	"strconv"
 )
 
 /*
@@ -21,14 +30,39 @@ Configuration parameters
 
 - connection(s):
    - discovery_key:               (optional) a key to retrieve the connection from IDiscovery
-   - host:                        host name or IP address
-   - port:                        port number (default: 27017)
+   - host:                        host name or IP address, or comma separated list of hosts with optional ports
+   - port:                        port number (default: 5432)
    - database:                    database name
    - uri:                         resource URI or connection string with all parameters in it
+   - role:                        (optional) "primary" (default) or "replica" for read-only replicas
+   - target_session_attrs:        (optional) required session attributes: read-write, read-only, primary, standby, prefer-standby or any
+   - sslmode:                     (optional) SSL mode: disable, allow, prefer, require, verify-ca or verify-full
+   - sslrootcert:                 (optional) path to a file with root CA certificates
+   - sslcert:                     (optional) path to a file with client certificate
+   - sslkey:                      (optional) path to a file with client private key
+   - sslservername:               (optional) server name to verify in verify-full mode instead of the host
 - credential(s):
    - store_key:                   (optional) a key to retrieve the credentials from ICredentialStore
    - username:                    user name
    - password:                    user password
+   - sslrootcert_pem:             (optional) PEM encoded root CA certificates
+   - sslcert_pem:                 (optional) PEM encoded client certificate
+   - sslkey_pem:                  (optional) PEM encoded client private key
+
+Host can be a directory of unix domain socket, like /var/run/postgresql.
+
+Connection parameters are resolved in the following order of precedence:
+ 1. connection and credential parameters, including those from IDiscovery and ICredentialStore
+ 2. standard PGHOST, PGPORT, PGDATABASE, PGUSER and PGPASSWORD environment variables
+    (like in libpq, database defaults to the user name and host of a connection
+    defined only by the environment defaults to the local socket directory)
+ 3. password file defined by PGPASSFILE or ~/.pgpass, used when password is not set
+    (unix socket connections are matched by "localhost" host name)
+ 4. other PG* environment variables and defaults of the driver, like port 5432
+
+SSL parameters can be set either in connection or in credential parameters.
+PEM contents are usually kept in a credential store, while file paths are set in the connection.
+When any of SSL parameters is set, sslmode must be set explicitly.
 
  References
 
@@ -40,6 +74,17 @@ type PostgresConnectionResolver struct {
 	ConnectionResolver ccon.ConnectionResolver
 	//The credentials resolver.
 	CredentialResolver auth.CredentialResolver
+
+	sslError error
+}
+
+// Default PostgreSQL port
+const defaultPort = 5432
+
+// Connection and credential parameters that define SSL configuration
+var sslParams = []string{
+	"sslmode", "sslrootcert", "sslcert", "sslkey", "sslservername",
+	"sslrootcert_pem", "sslcert_pem", "sslkey_pem",
 }
 
 // NewPostgresConnectionResolver creates new connection resolver
@@ -58,6 +103,15 @@ func NewPostgresConnectionResolver() *PostgresConnectionResolver {
 func (c *PostgresConnectionResolver) Configure(config *cconf.ConfigParams) {
 	c.ConnectionResolver.Configure(config)
 	c.CredentialResolver.Configure(config)
+
+	// Validate SSL parameters early. The error is returned on Resolve
+	credentials := auth.NewManyCredentialParamsFromConfig(config)
+	var credential *auth.CredentialParams
+	if len(credentials) > 0 {
+		credential = credentials[0]
+	}
+	ssl := c.composeSsl(ccon.NewManyConnectionParamsFromConfig(config), credential)
+	c.sslError = c.validateSsl("", ssl)
 }
 
 // SetReferences is sets references to dependent components.
@@ -79,17 +133,47 @@ func (c *PostgresConnectionResolver) validateConnection(correlationId string, co
 	if host == "" {
 		return cerr.NewConfigError(correlationId, "NO_HOST", "Connection host is not set")
 	}
-	port := connection.Port()
-	if port == 0 {
-		return cerr.NewConfigError(correlationId, "NO_PORT", "Connection port is not set")
-	}
 	database := connection.GetAsNullableString("database")
-	if *database == "" {
+	if database == nil || *database == "" {
 		return cerr.NewConfigError(correlationId, "NO_DATABASE", "Connection database is not set")
 	}
 	return nil
 }
 
+// Splits the host parameter of the connection into an ordered list of hosts
+// and ports. Hosts without explicit port get the connection port or the default port.
+// Unix socket directories are returned as is.
+func (c *PostgresConnectionResolver) composeHosts(connection *ccon.ConnectionParams) (hosts []string, ports []string) {
+	port := strconv.Itoa(connection.PortWithDefault(defaultPort))
+
+	hosts = make([]string, 0)
+	ports = make([]string, 0)
+	for _, host := range strings.Split(connection.Host(), ",") {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+		hostPort := port
+		if strings.HasPrefix(host, "/") {
+			// Unix socket directory
+		} else if strings.HasPrefix(host, "[") {
+			// IPv6 address in brackets
+			if index := strings.LastIndex(host, "]:"); index >= 0 {
+				host, hostPort = host[:index+1], host[index+2:]
+			}
+		} else if count := strings.Count(host, ":"); count == 1 {
+			index := strings.LastIndex(host, ":")
+			host, hostPort = host[:index], host[index+1:]
+		} else if count > 1 {
+			// IPv6 address without brackets
+			host = "[" + host + "]"
+		}
+		hosts = append(hosts, host)
+		ports = append(ports, hostPort)
+	}
+	return hosts, ports
+}
+
 func (c *PostgresConnectionResolver) validateConnections(correlationId string, connections []*ccon.ConnectionParams) error {
 	if connections == nil || len(connections) == 0 {
 		return cerr.NewConfigError(correlationId, "NO_CONNECTION", "Database connection is not set")
@@ -101,6 +185,14 @@ func (c *PostgresConnectionResolver) validateConnections(correlationId string, c
 		}
 	}
 
+	attrs := c.composeTargetSessionAttrs(connections)
+	switch attrs {
+	case "", "read-write", "read-only", "primary", "standby", "prefer-standby", "any":
+	default:
+		return cerr.NewConfigError(correlationId, "INVALID_TARGET_SESSION_ATTRS", "Target session attributes "+attrs+" are not supported").
+			WithDetails("target_session_attrs", attrs)
+	}
+
 	return nil
 }
 
@@ -164,16 +256,30 @@ func (c *PostgresConnectionResolver) composeUri(connections []*ccon.ConnectionPa
 	}
 
 	// Define hosts
-	var hosts = ""
+	hosts := make([]string, 0)
+	ports := make([]string, 0)
+	hasSockets := false
 	for _, connection := range connections {
-		host := connection.Host()
-		port := connection.Port()
-
-		if len(hosts) > 0 {
-			hosts += ","
+		connectionHosts, connectionPorts := c.composeHosts(connection)
+		for index, host := range connectionHosts {
+			hasSockets = hasSockets || strings.HasPrefix(host, "/")
+			hosts = append(hosts, host)
+			ports = append(ports, connectionPorts[index])
 		}
-		if port != 0 {
-			hosts += host + ":" + strconv.Itoa(port)
+	}
+
+	// Unix socket directories can not be a part of URI authority,
+	// so in this case all hosts are passed as parameters
+	authority := ""
+	hostParams := ""
+	if hasSockets {
+		hostParams = "host=" + strings.Join(hosts, ",") + "&port=" + strings.Join(ports, ",")
+	} else {
+		for index, host := range hosts {
+			if len(authority) > 0 {
+				authority += ","
+			}
+			authority += host + ":" + ports[index]
 		}
 	}
 
@@ -181,11 +287,13 @@ func (c *PostgresConnectionResolver) composeUri(connections []*ccon.ConnectionPa
 	database := ""
 	for _, connection := range connections {
 		if database == "" {
-			database = *connection.GetAsNullableString("database")
+			database = connection.GetAsString("database")
 		}
 	}
 	if len(database) > 0 {
 		database = "/" + database
+	} else if hasSockets {
+		database = "/"
 	}
 
 	// Define authentication part
@@ -218,7 +326,13 @@ func (c *PostgresConnectionResolver) composeUri(connections []*ccon.ConnectionPa
 	options.Remove("database")
 	options.Remove("username")
 	options.Remove("password")
-	params := ""
+	options.Remove("role")
+	for _, key := range sslParams {
+		if key != "sslmode" {
+			options.Remove(key)
+		}
+	}
+	params := hostParams
 	keys := options.Keys()
 	for _, key := range keys {
 		if len(params) > 0 {
@@ -237,7 +351,7 @@ func (c *PostgresConnectionResolver) composeUri(connections []*ccon.ConnectionPa
 
 	// Compose uri
 
-	uri := "postgres://" + url.PathEscape(auth) + hosts + database + params
+	uri := "postgres://" + url.PathEscape(auth) + authority + database + params
 
 	return uri
 }
@@ -249,19 +363,427 @@ func (c *PostgresConnectionResolver) composeUri(connections []*ccon.ConnectionPa
 // Returns uri string, err error
 // resolved URI and error, if this occured.
 func (c *PostgresConnectionResolver) Resolve(correlationId string) (uri string, err error) {
+	if c.sslError != nil {
+		return "", c.sslError
+	}
 
 	connections, err := c.ConnectionResolver.ResolveAll(correlationId)
 	//Validate connections
 	if err != nil {
 		return "", err
 	}
+	credential, err := c.CredentialResolver.Lookup(correlationId)
+	if err != nil {
+		return "", err
+	}
+	credential = c.applyCredentialEnvironment(credential)
+	connections, _ = c.splitConnections(connections)
+	connections = c.applyEnvironment(connections, credential)
 	err = c.validateConnections(correlationId, connections)
 	if err != nil {
 		return "", err
 	}
+	return c.composeUri(connections, credential), nil
+}
+
+func (c *PostgresConnectionResolver) composeSsl(connections []*ccon.ConnectionParams, credential *auth.CredentialParams) map[string]string {
+	ssl := make(map[string]string)
+	for _, key := range sslParams {
+		for _, connection := range connections {
+			if value := connection.GetAsString(key); value != "" {
+				ssl[key] = value
+				break
+			}
+		}
+		if _, ok := ssl[key]; !ok && credential != nil {
+			if value := credential.GetAsString(key); value != "" {
+				ssl[key] = value
+			}
+		}
+	}
+	return ssl
+}
+
+func (c *PostgresConnectionResolver) validateSsl(correlationId string, ssl map[string]string) error {
+	mode := ssl["sslmode"]
+	if mode == "" {
+		if len(ssl) > 0 {
+			return cerr.NewConfigError(correlationId, "NO_SSL_MODE", "SSL parameters are set but sslmode is not defined")
+		}
+		return nil
+	}
+
+	switch mode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		return cerr.NewConfigError(correlationId, "INVALID_SSL_MODE", "SSL mode "+mode+" is not supported").
+			WithDetails("sslmode", mode)
+	}
+
+	if ssl["sslrootcert"] != "" && ssl["sslrootcert_pem"] != "" {
+		return cerr.NewConfigError(correlationId, "AMBIGUOUS_SSL_ROOT_CERT", "Only one of sslrootcert and sslrootcert_pem can be set")
+	}
+	if ssl["sslcert"] != "" && ssl["sslcert_pem"] != "" {
+		return cerr.NewConfigError(correlationId, "AMBIGUOUS_SSL_CERT", "Only one of sslcert and sslcert_pem can be set")
+	}
+	if ssl["sslkey"] != "" && ssl["sslkey_pem"] != "" {
+		return cerr.NewConfigError(correlationId, "AMBIGUOUS_SSL_KEY", "Only one of sslkey and sslkey_pem can be set")
+	}
+
+	hasRootCert := ssl["sslrootcert"] != "" || ssl["sslrootcert_pem"] != ""
+	hasCert := ssl["sslcert"] != "" || ssl["sslcert_pem"] != ""
+	hasKey := ssl["sslkey"] != "" || ssl["sslkey_pem"] != ""
+
+	if hasCert != hasKey {
+		return cerr.NewConfigError(correlationId, "INCOMPLETE_SSL_CERT", "Both client certificate and key must be set")
+	}
+	if mode == "disable" && (hasRootCert || hasCert) {
+		return cerr.NewConfigError(correlationId, "INSECURE_SSL_CONFIG", "SSL certificates are set but sslmode is disable")
+	}
+	if ssl["sslservername"] != "" && mode != "verify-full" {
+		return cerr.NewConfigError(correlationId, "INSECURE_SSL_CONFIG", "SSL server name can only be used with sslmode verify-full")
+	}
+	return nil
+}
+
+// ResolveTLSConfig resolves TLS configuration from SSL connection and credential parameters.
+// When no SSL parameters besides sslmode are set it returns nil,
+// so the driver defaults for the sslmode in connection URI are used.
+// In verify-full mode ServerName is set to sslservername or left empty
+// to be set to the connected host.
+// Parameters:
+//   - correlationId  string
+//   (optional) transaction id to trace execution through call chain.
+// Returns TLS configuration or nil and error, if this occured.
+func (c *PostgresConnectionResolver) ResolveTLSConfig(correlationId string) (*tls.Config, error) {
+	if c.sslError != nil {
+		return nil, c.sslError
+	}
+
+	connections, err := c.ConnectionResolver.ResolveAll(correlationId)
+	if err != nil {
+		return nil, err
+	}
 	credential, err := c.CredentialResolver.Lookup(correlationId)
+	if err != nil {
+		return nil, err
+	}
+
+	ssl := c.composeSsl(connections, credential)
+	err = c.validateSsl(correlationId, ssl)
+	if err != nil {
+		return nil, err
+	}
+
+	mode := ssl["sslmode"]
+	if mode == "" || mode == "disable" || len(ssl) == 1 {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{}
+
+	rootCert, err := c.readSslParam(correlationId, ssl, "sslrootcert")
+	if err != nil {
+		return nil, err
+	}
+	if rootCert != nil {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(rootCert) {
+			return nil, cerr.NewConfigError(correlationId, "INVALID_SSL_ROOT_CERT", "Failed to parse SSL root CA certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	cert, err := c.readSslParam(correlationId, ssl, "sslcert")
+	if err != nil {
+		return nil, err
+	}
+	key, err := c.readSslParam(correlationId, ssl, "sslkey")
+	if err != nil {
+		return nil, err
+	}
+	if cert != nil && key != nil {
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, cerr.NewConfigError(correlationId, "INVALID_SSL_CERT", "Failed to load SSL client certificate").WithCause(err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{pair}
+	}
+
+	switch mode {
+	case "allow", "prefer":
+		tlsConfig.InsecureSkipVerify = true
+	case "require", "verify-ca":
+		// Same as libpq: require with root CA behaves as verify-ca
+		// System roots are used by verify-ca when root CA is not set
+		tlsConfig.InsecureSkipVerify = true
+		if mode == "verify-ca" || tlsConfig.RootCAs != nil {
+			roots := tlsConfig.RootCAs
+			tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+				return verifyCertificateChain(rawCerts, roots)
+			}
+		}
+	case "verify-full":
+		tlsConfig.ServerName = ssl["sslservername"]
+	}
+
+	return tlsConfig, nil
+}
+
+func (c *PostgresConnectionResolver) readSslParam(correlationId string, ssl map[string]string, name string) ([]byte, error) {
+	if value := ssl[name+"_pem"]; value != "" {
+		return []byte(value), nil
+	}
+	if path := ssl[name]; path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, cerr.NewFileError(correlationId, "READ_FAILED", "Failed to read SSL file "+path).
+				WithDetails("param", name).WithCause(err)
+		}
+		return data, nil
+	}
+	return nil, nil
+}
+
+// Verifies server certificate chain without verification of the host name
+func verifyCertificateChain(rawCerts [][]byte, roots *x509.CertPool) error {
+	if len(rawCerts) == 0 {
+		return errors.New("server did not provide a certificate")
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+	}
+	var leaf *x509.Certificate
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return errors.New("failed to parse certificate from server: " + err.Error())
+		}
+		if i == 0 {
+			leaf = cert
+		} else {
+			opts.Intermediates.AddCert(cert)
+		}
+	}
+	_, err := leaf.Verify(opts)
+	return err
+}
+
+// Splits connections into primary and replica connections by their role
+func (c *PostgresConnectionResolver) splitConnections(connections []*ccon.ConnectionParams) (primary []*ccon.ConnectionParams, replicas []*ccon.ConnectionParams) {
+	primary = make([]*ccon.ConnectionParams, 0)
+	replicas = make([]*ccon.ConnectionParams, 0)
+	for _, connection := range connections {
+		if connection.GetAsString("role") == "replica" {
+			replicas = append(replicas, connection)
+		} else {
+			primary = append(primary, connection)
+		}
+	}
+	return primary, replicas
+}
+
+// ResolveReplicas method are resolves Postgres connection URIs for replicas,
+// defined by connections with role "replica". Replicas without database name
+// use the database of the primary connection.
+// Parameters:
+//   - correlationId  string
+//   (optional) transaction id to trace execution through call chain.
+// Returns uris []string, err error
+// resolved replica URIs and error, if this occured.
+func (c *PostgresConnectionResolver) ResolveReplicas(correlationId string) (uris []string, err error) {
+	connections, err := c.ConnectionResolver.ResolveAll(correlationId)
+	if err != nil {
+		return nil, err
+	}
+	primary, replicas := c.splitConnections(connections)
+	if len(replicas) == 0 {
+		return []string{}, nil
+	}
+
+	credential, err := c.CredentialResolver.Lookup(correlationId)
+	if err != nil {
+		return nil, err
+	}
+
+	credential = c.applyCredentialEnvironment(credential)
+	primary = c.applyEnvironment(primary, credential)
+
+	database := ""
+	for _, connection := range primary {
+		if database = connection.GetAsString("database"); database != "" {
+			break
+		}
+	}
+
+	uris = make([]string, 0, len(replicas))
+	for _, replica := range replicas {
+		if replica.Uri() == "" && replica.GetAsString("database") == "" && database != "" {
+			replica = ccon.NewConnectionParamsFromMaps(replica.Value())
+			replica.Put("database", database)
+		}
+		err = c.validateConnection(correlationId, replica)
+		if err != nil {
+			return nil, err
+		}
+		uris = append(uris, c.composeUri([]*ccon.ConnectionParams{replica}, credential))
+	}
+	return uris, nil
+}
+
+func (c *PostgresConnectionResolver) composeTargetSessionAttrs(connections []*ccon.ConnectionParams) string {
+	for _, connection := range connections {
+		if attrs := connection.GetAsString("target_session_attrs"); attrs != "" {
+			return attrs
+		}
+		if uri := connection.Uri(); uri != "" {
+			if parsed, err := url.Parse(uri); err == nil && parsed.Scheme != "" {
+				if attrs := parsed.Query().Get("target_session_attrs"); attrs != "" {
+					return attrs
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// ResolveTargetSessionAttrs method are resolves target_session_attrs of the primary connections.
+// Parameters:
+//   - correlationId  string
+//   (optional) transaction id to trace execution through call chain.
+// Returns attrs string, err error
+// target session attributes or empty string when they are not set, and error, if this occured.
+func (c *PostgresConnectionResolver) ResolveTargetSessionAttrs(correlationId string) (attrs string, err error) {
+	connections, err := c.ConnectionResolver.ResolveAll(correlationId)
 	if err != nil {
 		return "", err
 	}
-	return c.composeUri(connections, credential), nil
+	connections, _ = c.splitConnections(connections)
+	return c.composeTargetSessionAttrs(connections), nil
+}
+
+var (
+	uriPasswordRegex   = regexp.MustCompile(`(?i)(postgres(?:ql)?://[^:/@\s]*:)[^\s/?#]*@`)
+	paramPasswordRegex = regexp.MustCompile(`(?i)\b(password|sslpassword)=('(?:[^'\\]|\\.)*'|[^\s&]*)`)
+)
+
+// RedactUri replaces passwords in connection URIs and key-value connection strings
+// contained in the value, so it can be safely logged or returned in errors.
+// Parameters:
+//   - value string
+//   a connection URI, connection string or error message
+// Returns the value with passwords replaced by "xxxxx".
+func RedactUri(value string) string {
+	// Passwords may contain '@', so connection URIs are split by the parser
+	if uri, err := url.Parse(value); err == nil &&
+		(strings.EqualFold(uri.Scheme, "postgres") || strings.EqualFold(uri.Scheme, "postgresql")) {
+		if uri.User != nil {
+			if _, ok := uri.User.Password(); ok {
+				uri.User = url.UserPassword(uri.User.Username(), "xxxxx")
+			}
+		}
+		uri.RawQuery = paramPasswordRegex.ReplaceAllString(uri.RawQuery, "${1}=xxxxx")
+		return uri.String()
+	}
+
+	// Error messages and connection strings are redacted by patterns
+	value = uriPasswordRegex.ReplaceAllString(value, "${1}xxxxx@")
+	value = paramPasswordRegex.ReplaceAllString(value, "${1}=xxxxx")
+	return value
+}
+
+// Fills host, port and database missing in connections from standard
+// PGHOST, PGPORT and PGDATABASE environment variables.
+// When there are no connections but any of PGHOST, PGPORT, PGDATABASE or PGUSER
+// is set, a connection is created from the environment. Like in libpq, its host
+// defaults to the local socket directory. Database of all connections defaults
+// to the user name. Host of configured connections has no default,
+// since a configured connection without host is more likely a mistake.
+func (c *PostgresConnectionResolver) applyEnvironment(connections []*ccon.ConnectionParams,
+	credential *auth.CredentialParams) []*ccon.ConnectionParams {
+	host := os.Getenv("PGHOST")
+	port := os.Getenv("PGPORT")
+	database := os.Getenv("PGDATABASE")
+
+	if len(connections) == 0 {
+		if host == "" && port == "" && database == "" && os.Getenv("PGUSER") == "" {
+			return connections
+		}
+		if host == "" {
+			host = defaultSocketDir()
+		}
+		connections = []*ccon.ConnectionParams{ccon.NewEmptyConnectionParams()}
+	}
+	if database == "" {
+		database = defaultUsername(credential)
+	}
+
+	result := make([]*ccon.ConnectionParams, 0, len(connections))
+	for _, connection := range connections {
+		if connection.Uri() != "" {
+			result = append(result, connection)
+			continue
+		}
+		connection = ccon.NewConnectionParamsFromMaps(connection.Value())
+		if connection.Host() == "" && host != "" {
+			connection.SetHost(host)
+		}
+		if connection.Port() == 0 && port != "" {
+			connection.Put("port", port)
+		}
+		if connection.GetAsString("database") == "" && database != "" {
+			connection.Put("database", database)
+		}
+		result = append(result, connection)
+	}
+	return result
+}
+
+// Returns the first existing local socket directory checked by the driver,
+// or "localhost" when there is none.
+func defaultSocketDir() string {
+	for _, dir := range []string{"/var/run/postgresql", "/private/tmp", "/tmp"} {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+	}
+	return "localhost"
+}
+
+// Returns the user name from credential, or the name of the current OS user
+// used by the driver when the credential has no user name.
+func defaultUsername(credential *auth.CredentialParams) string {
+	if credential != nil && credential.Username() != "" {
+		return credential.Username()
+	}
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return ""
+}
+
+// Fills username and password missing in credential from standard
+// PGUSER and PGPASSWORD environment variables.
+// If password is still not set, the driver looks it up in the password file
+// defined by PGPASSFILE or in ~/.pgpass.
+func (c *PostgresConnectionResolver) applyCredentialEnvironment(credential *auth.CredentialParams) *auth.CredentialParams {
+	username := os.Getenv("PGUSER")
+	password := os.Getenv("PGPASSWORD")
+	if username == "" && password == "" {
+		return credential
+	}
+
+	if credential == nil {
+		credential = auth.NewEmptyCredentialParams()
+	} else {
+		credential = auth.NewCredentialParamsFromMaps(credential.Value())
+	}
+	if credential.Username() == "" && username != "" {
+		credential.SetUsername(username)
+	}
+	if credential.Password() == "" && password != "" {
+		credential.SetPassword(password)
+	}
+	return credential
 }
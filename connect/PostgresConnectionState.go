@@ -0,0 +1,18 @@
+package connect
+
+// PostgresConnectionState defines a state of PostgreSQL connection
+// reported to state listeners of PostgresConnection.
+type PostgresConnectionState string
+
+const (
+	// The connection is being established for the first time.
+	PostgresConnectionConnecting PostgresConnectionState = "connecting"
+	// The connection is established and the database responds.
+	PostgresConnectionConnected PostgresConnectionState = "connected"
+	// The database stopped responding and the pool is being restored.
+	PostgresConnectionReconnecting PostgresConnectionState = "reconnecting"
+	// The connection failed and is not going to be restored.
+	PostgresConnectionFailed PostgresConnectionState = "failed"
+	// The connection was closed.
+	PostgresConnectionClosed PostgresConnectionState = "closed"
+)
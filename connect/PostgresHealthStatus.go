@@ -0,0 +1,32 @@
+package connect
+
+import "time"
+
+// PostgresHealthStatus is a result of PostgreSQL connection health check.
+// It is intended to be serialized as is by status and readiness endpoints.
+type PostgresHealthStatus struct {
+	// True if the database responded to ping within the timeout.
+	Healthy bool `json:"healthy"`
+	// The time when the check was performed.
+	CheckedAt time.Time `json:"checked_at"`
+	// The round trip time of the health check query in milliseconds.
+	LatencyMs int64 `json:"latency_ms"`
+	// The PostgreSQL server version.
+	ServerVersion string `json:"server_version,omitempty"`
+	// True if the server is in recovery mode (a replica).
+	InRecovery bool `json:"in_recovery"`
+	// The total number of connections in the pool.
+	TotalConns int32 `json:"total_conns"`
+	// The number of connections currently used by clients.
+	AcquiredConns int32 `json:"acquired_conns"`
+	// The number of idle connections in the pool.
+	IdleConns int32 `json:"idle_conns"`
+	// The maximum size of the pool.
+	MaxConns int32 `json:"max_conns"`
+	// The ratio of acquired connections to the maximum pool size (0..1).
+	Saturation float64 `json:"saturation"`
+	// The error message when the check failed.
+	Error string `json:"error,omitempty"`
+	// The health of read replicas. It does not affect the overall health status.
+	Replicas []*PostgresHealthStatus `json:"replicas,omitempty"`
+}
@@ -3,6 +3,9 @@ module github.com/pip-services3-go/pip-services3-postgres-go
 go 1.16
 
 require (
+	github.com/jackc/pgconn v1.13.0
+	github.com/jackc/pgproto3/v2 v2.3.1
+	github.com/jackc/pgtype v1.12.0
 	github.com/jackc/pgx/v4 v4.17.2
 	github.com/pip-services3-go/pip-services3-commons-go v1.1.6
 	github.com/pip-services3-go/pip-services3-components-go v1.3.2
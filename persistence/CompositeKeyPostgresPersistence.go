@@ -0,0 +1,414 @@
+package persistence
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+
+	cdata "github.com/pip-services3-go/pip-services3-commons-go/data"
+	cerr "github.com/pip-services3-go/pip-services3-commons-go/errors"
+)
+
+/*
+Abstract persistence component that stores data in PostgreSQL
+and implements a number of CRUD operations over data items with a composite primary key,
+like (tenant_id, entity_id) or (parent_id, seq).
+
+A key is a list of values in the order of key columns declared in the constructor.
+Key values are never generated, data items must have all key columns set.
+
+In basic scenarios child classes shall only override getPageByFilter,
+getListByFilter or deleteByFilter operations with specific filter function.
+All other operations can be used out of the box.
+
+### Configuration parameters ###
+
+- collection:                  (optional) PostgreSQL collection name
+- connection(s):
+   - discovery_key:             (optional) a key to retrieve the connection from IDiscovery
+   - host:                      host name or IP address
+   - port:                      port number (default: 27017)
+   - uri:                       resource URI or connection string with all parameters in it
+- credential(s):
+   - store_key:                 (optional) a key to retrieve the credentials from ICredentialStore
+   - username:                  (optional) user name
+   - password:                  (optional) user password
+- options:
+   - connect_timeout:      (optional) number of milliseconds to wait before timing out when connecting a new client (default: 0)
+   - idle_timeout:         (optional) number of milliseconds a client must sit idle in the pool and not be checked out (default: 10000)
+   - max_pool_size:        (optional) maximum number of clients the pool should contain (default: 10)
+
+### References ###
+
+- \*:logger:\*:\*:1.0           (optional) ILogger components to pass log messages components to pass log messages
+- \*:discovery:\*:\*:1.0        (optional) IDiscovery services
+- \*:credential-store:\*:\*:1.0 (optional) Credential stores to resolve credentials
+
+### Example ###
+
+    type MyPostgresPersistence struct {
+        persist.CompositeKeyPostgresPersistence
+    }
+
+    func NewMyPostgresPersistence() *MyPostgresPersistence {
+        c := &MyPostgresPersistence{}
+        c.CompositeKeyPostgresPersistence = *persist.InheritCompositeKeyPostgresPersistence(c,
+            reflect.TypeOf(MyData{}), "mydata", []string{"tenant_id", "id"})
+        return c
+    }
+
+    item, err := persistence.GetOneByKey("123", []interface{}{"tenant1", "1"})
+*/
+type CompositeKeyPostgresPersistence struct {
+	*PostgresPersistence
+	//The names of the columns that form the primary key.
+	KeyColumns []string
+}
+
+// Creates a new instance of the persistence component.
+//   - overrides References to override virtual methods
+//   - tableName    a table name.
+//   - keyColumns   names of the columns that form the primary key.
+func InheritCompositeKeyPostgresPersistence(overrides IPostgresPersistenceOverrides, proto reflect.Type,
+	tableName string, keyColumns []string) *CompositeKeyPostgresPersistence {
+	if tableName == "" {
+		panic("Table name could not be empty")
+	}
+	if len(keyColumns) == 0 {
+		panic("Key columns could not be empty")
+	}
+
+	c := &CompositeKeyPostgresPersistence{}
+	c.PostgresPersistence = InheritPostgresPersistence(overrides, proto, tableName)
+	c.KeyColumns = append([]string{}, keyColumns...)
+
+	return c
+}
+
+// Generates a quoted list of key columns like: "column1","column2"
+func (c *CompositeKeyPostgresPersistence) quotedKeyColumns() string {
+	columns := make([]string, len(c.KeyColumns))
+	for index, column := range c.KeyColumns {
+		columns[index] = c.QuoteIdentifier(column)
+	}
+	return strings.Join(columns, ",")
+}
+
+// Checks that the key has a value for every key column.
+// Like ids of IdentifiablePostgresPersistence, empty strings are treated as not set.
+func (c *CompositeKeyPostgresPersistence) checkKey(correlationId string, key []interface{}) error {
+	if len(key) != len(c.KeyColumns) {
+		return cerr.NewBadRequestError(correlationId, "INVALID_KEY",
+			"Key must have "+strconv.Itoa(len(c.KeyColumns))+" values").
+			WithDetails("columns", c.KeyColumns).
+			WithDetails("key", key)
+	}
+	for index, value := range key {
+		if value == nil || value == "" {
+			return cerr.NewBadRequestError(correlationId, "INVALID_KEY",
+				"Key column "+c.KeyColumns[index]+" is not set").
+				WithDetails("columns", c.KeyColumns).
+				WithDetails("key", key)
+		}
+	}
+	return nil
+}
+
+// Generates a condition on key columns like: "column1"=$1 AND "column2"=$2
+//   - offset    number of parameters that precede the key values
+func (c *CompositeKeyPostgresPersistence) composeKeyCondition(offset int) string {
+	conditions := make([]string, len(c.KeyColumns))
+	for index, column := range c.KeyColumns {
+		conditions[index] = c.QuoteIdentifier(column) + "=$" + strconv.Itoa(offset+index+1)
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+// Generates a condition on a list of keys like: ("column1","column2") IN (($1,$2),($3,$4))
+func (c *CompositeKeyPostgresPersistence) composeKeysCondition(correlationId string, keys [][]interface{}) (string, []interface{}, error) {
+	tuples := make([]string, len(keys))
+	values := make([]interface{}, 0, len(keys)*len(c.KeyColumns))
+	for index, key := range keys {
+		if err := c.checkKey(correlationId, key); err != nil {
+			return "", nil, err
+		}
+		params := make([]string, len(key))
+		for keyIndex := range key {
+			params[keyIndex] = "$" + strconv.Itoa(len(values)+keyIndex+1)
+		}
+		tuples[index] = "(" + strings.Join(params, ",") + ")"
+		values = append(values, key...)
+	}
+	return "(" + c.quotedKeyColumns() + ") IN (" + strings.Join(tuples, ",") + ")", values, nil
+}
+
+// Converts an item into a row and takes values of key columns from it.
+func (c *CompositeKeyPostgresPersistence) convertToRow(correlationId string, item interface{}) (map[string]interface{}, []interface{}, error) {
+	row := c.convertToMap(c.Overrides.ConvertFromPublic(item))
+	if row == nil {
+		return nil, nil, cerr.NewInternalError(correlationId, "CONVERSION_FAILED",
+			"Failed to convert item to a row of "+c.QuotedTableName())
+	}
+	key := make([]interface{}, len(c.KeyColumns))
+	for index, column := range c.KeyColumns {
+		key[index] = row[column]
+	}
+	if err := c.checkKey(correlationId, key); err != nil {
+		return nil, nil, err
+	}
+	return row, key, nil
+}
+
+// Gets a key of the data item.
+//   - item              a data item in public format.
+// Returns          values of key columns in the order of KeyColumns.
+func (c *CompositeKeyPostgresPersistence) GetKey(item interface{}) []interface{} {
+	_, key, err := c.convertToRow("", item)
+	if err != nil {
+		return nil
+	}
+	return key
+}
+
+// Gets a list of data items retrieved by given keys.
+//   - correlationId     (optional) transaction id to trace execution through call chain.
+//   - keys              keys of data items to be retrieved
+// Returns          a data list or error.
+func (c *CompositeKeyPostgresPersistence) GetListByKeys(correlationId string, keys [][]interface{}) (items []interface{}, err error) {
+	items = make([]interface{}, 0, len(keys))
+	if len(keys) == 0 {
+		return items, nil
+	}
+	condition, values, err := c.composeKeysCondition(correlationId, keys)
+	if err != nil {
+		return nil, err
+	}
+	query := "SELECT * FROM " + c.QuotedTableName() + " WHERE " + condition
+
+	qResult, qErr := c.ReadQuery(context.TODO(), correlationId, query, values...)
+	if qErr != nil {
+		return nil, qErr
+	}
+	defer qResult.Close()
+	for qResult.Next() {
+		item := c.Overrides.ConvertToPublic(qResult)
+		items = append(items, item)
+	}
+
+	c.Logger.Trace(correlationId, "Retrieved %d from %s", len(items), c.TableName)
+	return items, qResult.Err()
+}
+
+// Gets a data item by its key.
+//   - correlationId     (optional) transaction id to trace execution through call chain.
+//   - key               a key of data item to be retrieved.
+// Returns           data item or error.
+func (c *CompositeKeyPostgresPersistence) GetOneByKey(correlationId string, key []interface{}) (item interface{}, err error) {
+	if err = c.checkKey(correlationId, key); err != nil {
+		return nil, err
+	}
+	query := "SELECT * FROM " + c.QuotedTableName() + " WHERE " + c.composeKeyCondition(0)
+
+	qResult, qErr := c.ReadQuery(context.TODO(), correlationId, query, key...)
+	if qErr != nil {
+		return nil, qErr
+	}
+	defer qResult.Close()
+	if !qResult.Next() {
+		c.Logger.Trace(correlationId, "Nothing found from %s with key = %v", c.TableName, key)
+		return nil, qResult.Err()
+	}
+	item = c.Overrides.ConvertToPublic(qResult)
+	c.Logger.Trace(correlationId, "Retrieved from %s with key = %v", c.TableName, key)
+	return item, nil
+}
+
+// Creates a data item.
+//   - correlation_id    (optional) transaction id to trace execution through call chain.
+//   - item              an item to be created.
+// Returns          (optional)  created item or error.
+func (c *CompositeKeyPostgresPersistence) Create(correlationId string, item interface{}) (result interface{}, err error) {
+	if item == nil {
+		return nil, nil
+	}
+	row, _, err := c.convertToRow(correlationId, item)
+	if err != nil {
+		return nil, err
+	}
+	return c.create(correlationId, row)
+}
+
+// Sets a data item. If the data item with the same key exists it updates it,
+// otherwise it create a new data item.
+//   - correlation_id    (optional) transaction id to trace execution through call chain.
+//   - item              a item to be set.
+// Returns          (optional)  updated item or error.
+func (c *CompositeKeyPostgresPersistence) Set(correlationId string, item interface{}) (result interface{}, err error) {
+	if item == nil {
+		return nil, nil
+	}
+	row, key, err := c.convertToRow(correlationId, item)
+	if err != nil {
+		return nil, err
+	}
+	params := c.GenerateParameters(row)
+	setParams, columns := c.GenerateSetParameters(row)
+	values := c.GenerateValues(columns, row)
+
+	query := "INSERT INTO " + c.QuotedTableName() + " (" + columns + ")" +
+		" VALUES (" + params + ")" +
+		" ON CONFLICT (" + c.quotedKeyColumns() + ") DO UPDATE SET " + setParams + " RETURNING *"
+
+	qResult, qErr := c.Query(context.TODO(), correlationId, query, values...)
+	if qErr != nil {
+		return nil, qErr
+	}
+	defer qResult.Close()
+	if !qResult.Next() {
+		return nil, qResult.Err()
+	}
+	result = c.Overrides.ConvertToPublic(qResult)
+	c.Logger.Trace(correlationId, "Set in %s with key = %v", c.TableName, key)
+	return result, nil
+}
+
+// Updates a data item found by the key of the item.
+//   - correlation_id    (optional) transaction id to trace execution through call chain.
+//   - item              an item to be updated.
+// Returns          (optional)  updated item or error.
+func (c *CompositeKeyPostgresPersistence) Update(correlationId string, item interface{}) (result interface{}, err error) {
+	if item == nil {
+		return nil, nil
+	}
+	row, key, err := c.convertToRow(correlationId, item)
+	if err != nil {
+		return nil, err
+	}
+	params, columns := c.GenerateSetParameters(row)
+	values := c.GenerateValues(columns, row)
+
+	query := "UPDATE " + c.QuotedTableName() +
+		" SET " + params + " WHERE " + c.composeKeyCondition(len(values)) + " RETURNING *"
+	values = append(values, key...)
+
+	qResult, qErr := c.Query(context.TODO(), correlationId, query, values...)
+	if qErr != nil {
+		return nil, qErr
+	}
+	defer qResult.Close()
+	if !qResult.Next() {
+		return nil, qResult.Err()
+	}
+	result = c.Overrides.ConvertToPublic(qResult)
+	c.Logger.Trace(correlationId, "Updated in %s with key = %v", c.TableName, key)
+	return result, nil
+}
+
+// Updates only few selected fields in a data item.
+//   - correlation_id    (optional) transaction id to trace execution through call chain.
+//   - key               a key of data item to be updated.
+//   - data              a map with fields to be updated.
+// Returns           updated item or error.
+func (c *CompositeKeyPostgresPersistence) UpdatePartially(correlationId string, key []interface{}, data *cdata.AnyValueMap) (result interface{}, err error) {
+	if data == nil {
+		return nil, nil
+	}
+	if err = c.checkKey(correlationId, key); err != nil {
+		return nil, err
+	}
+
+	row := c.Overrides.ConvertFromPublicPartial(data.Value())
+	params, columns := c.GenerateSetParameters(row)
+	values := c.GenerateValues(columns, row)
+
+	query := "UPDATE " + c.QuotedTableName() +
+		" SET " + params + " WHERE " + c.composeKeyCondition(len(values)) + " RETURNING *"
+	values = append(values, key...)
+
+	qResult, qErr := c.Query(context.TODO(), correlationId, query, values...)
+	if qErr != nil {
+		return nil, qErr
+	}
+	defer qResult.Close()
+	if !qResult.Next() {
+		return nil, qResult.Err()
+	}
+	result = c.Overrides.ConvertToPublic(qResult)
+	c.Logger.Trace(correlationId, "Updated partially in %s with key = %v", c.TableName, key)
+	return result, nil
+}
+
+// Updates a data item by atomic operations like increments or array appends
+// in one statement without reading the item first.
+//   - correlation_id    (optional) transaction id to trace execution through call chain.
+//   - key               a key of data item to be updated.
+//   - operations        operations to apply to columns of the item.
+// Returns           updated item or error.
+func (c *CompositeKeyPostgresPersistence) UpdateByOperations(correlationId string, key []interface{},
+	operations *PostgresUpdateOperations) (result interface{}, err error) {
+
+	if operations == nil || operations.Len() == 0 {
+		return nil, nil
+	}
+	if err = c.checkKey(correlationId, key); err != nil {
+		return nil, err
+	}
+
+	result, err = c.updateByOperations(correlationId, c.composeKeyCondition(0), key, operations)
+	if err == nil && result != nil {
+		c.Logger.Trace(correlationId, "Updated by operations in %s with key = %v", c.TableName, key)
+	}
+	return result, err
+}
+
+// Deletes a data item by its key.
+//   - correlation_id    (optional) transaction id to trace execution through call chain.
+//   - key               a key of the item to be deleted
+// Returns          (optional)  deleted item or error.
+func (c *CompositeKeyPostgresPersistence) DeleteByKey(correlationId string, key []interface{}) (result interface{}, err error) {
+	if err = c.checkKey(correlationId, key); err != nil {
+		return nil, err
+	}
+	query := "DELETE FROM " + c.QuotedTableName() + " WHERE " + c.composeKeyCondition(0) + " RETURNING *"
+
+	qResult, qErr := c.Query(context.TODO(), correlationId, query, key...)
+	if qErr != nil {
+		return nil, qErr
+	}
+	defer qResult.Close()
+	if !qResult.Next() {
+		return nil, qResult.Err()
+	}
+	result = c.Overrides.ConvertToPublic(qResult)
+	c.Logger.Trace(correlationId, "Deleted from %s with key = %v", c.TableName, key)
+	return result, nil
+}
+
+// Deletes multiple data items by their keys.
+//   - correlationId     (optional) transaction id to trace execution through call chain.
+//   - keys              keys of data items to be deleted.
+// Returns          number of deleted items or error.
+func (c *CompositeKeyPostgresPersistence) DeleteByKeys(correlationId string, keys [][]interface{}) (count int64, err error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	condition, values, err := c.composeKeysCondition(correlationId, keys)
+	if err != nil {
+		return 0, err
+	}
+	query := "DELETE FROM " + c.QuotedTableName() + " WHERE " + condition
+
+	qResult, qErr := c.Query(context.TODO(), correlationId, query, values...)
+	if qErr != nil {
+		return 0, qErr
+	}
+	count, err = c.readAffectedCount(qResult)
+	if err != nil {
+		return 0, err
+	}
+	if count != 0 {
+		c.Logger.Trace(correlationId, "Deleted %d items from %s", count, c.TableName)
+	}
+	return count, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"reflect"
+	"strconv"
 
 	"github.com/jackc/pgx/v4"
 	cdata "github.com/pip-services3-go/pip-services3-commons-go/data"
@@ -106,11 +107,15 @@ type IdentifiableJsonPostgresPersistence struct {
 func InheritIdentifiableJsonPostgresPersistence(overrides IPostgresPersistenceOverrides, proto reflect.Type, tableName string) *IdentifiableJsonPostgresPersistence {
 	c := &IdentifiableJsonPostgresPersistence{}
 	c.IdentifiablePostgresPersistence = *InheritIdentifiablePostgresPersistence(overrides, proto, tableName)
+	c.idCopyColumn = "data"
+	c.jsonColumns = map[string]bool{"data": true}
+	c.idCopyKey = idFieldJsonName(proto)
 	return c
 }
 
 // Adds DML statement to automatically create JSON(B) table
-//   - idType type of the id column (default: TEXT)
+//   - idType type of the id column (default: TEXT), e.g. "BIGINT GENERATED BY DEFAULT AS IDENTITY"
+//            or "UUID DEFAULT gen_random_uuid()" for ids generated by the database
 //   - dataType type of the data column (default: JSONB)
 func (c *IdentifiableJsonPostgresPersistence) EnsureTable(idType string, dataType string) {
 	if idType == "" {
@@ -121,8 +126,13 @@ func (c *IdentifiableJsonPostgresPersistence) EnsureTable(idType string, dataTyp
 	}
 
 	query := "CREATE TABLE IF NOT EXISTS " + c.QuotedTableName() +
-		" (\"id\" " + idType + " PRIMARY KEY, \"data\" " + dataType + ")"
+		" (" + c.QuoteIdentifier(c.IdColumn) + " " + idType + " PRIMARY KEY, \"data\" " + dataType + ")"
 	c.EnsureSchema(query)
+
+	// Columns to verify the existing table
+	c.lock.Lock()
+	c.schemaColumns = map[string]string{c.IdColumn: idType, "data": dataType}
+	c.lock.Unlock()
 }
 
 // Converts object value from internal to public format.
@@ -164,8 +174,8 @@ func (c *IdentifiableJsonPostgresPersistence) ConvertFromPublic(value interface{
 	id := cmpersist.GetObjectId(value)
 
 	result := map[string]interface{}{
-		"id":   id,
-		"data": value,
+		c.IdColumn: id,
+		"data":     value,
 	}
 	return result
 }
@@ -188,10 +198,10 @@ func (c *IdentifiableJsonPostgresPersistence) UpdatePartially(correlationId stri
 		return nil, nil
 	}
 
-	query := "UPDATE " + c.QuotedTableName() + " SET \"data\"=\"data\"||$2 WHERE \"id\"=$1 RETURNING *"
+	query := "UPDATE " + c.QuotedTableName() + " SET \"data\"=\"data\"||$2 WHERE " + c.QuoteIdentifier(c.IdColumn) + "=$1 RETURNING *"
 	values := []interface{}{id, data.Value()}
 
-	qResult, qErr := c.Client.Query(context.TODO(), query, values...)
+	qResult, qErr := c.Query(context.TODO(), correlationId, query, values...)
 
 	if qErr != nil {
 		return nil, qErr
@@ -210,3 +220,55 @@ func (c *IdentifiableJsonPostgresPersistence) UpdatePartially(correlationId stri
 	return vErr, nil
 
 }
+
+// Updates only few selected fields in a data item only if the condition holds for the stored item.
+//   - correlation_id    (optional) transaction id to trace execution through call chain.
+//   - id                an id of data item to be updated.
+//   - data              a map with fields to be updated.
+//   - condition         a condition on the stored item with $1, $2... parameters, like data->>'status'=$1.
+//   - args              (optional) values of condition parameters.
+// Returns          updated item, NotFoundError with NOT_FOUND code when the item does not exist,
+//                  ConflictError with CONDITION_FAILED code when the condition does not hold, or other error.
+func (c *IdentifiableJsonPostgresPersistence) UpdatePartiallyIf(correlationId string, id interface{}, data *cdata.AnyValueMap,
+	condition string, args ...interface{}) (result interface{}, err error) {
+
+	if id == nil || data == nil {
+		return nil, nil
+	}
+	setParams, values := c.composeJsonPartialSet(data, args)
+	return c.updateIf(correlationId, id, condition, setParams, values)
+}
+
+// Updates only few selected fields in data items that match to a given filter.
+//   - correlationId     (optional) transaction id to trace execution through call chain.
+//   - filter            (optional) a filter condition with $1, $2... parameters.
+//   - data              a map with fields to be updated.
+//   - args              (optional) values of filter parameters.
+// Returns          number of updated items or error.
+func (c *IdentifiableJsonPostgresPersistence) UpdateByFilter(correlationId string, filter string, data *cdata.AnyValueMap, args ...interface{}) (count int64, err error) {
+	if data == nil {
+		return 0, nil
+	}
+	setParams, values := c.composeJsonPartialSet(data, args)
+	return c.updateByFilter(correlationId, filter, setParams, values)
+}
+
+// Updates only few selected fields in data items that match to a given filter and returns updated items.
+//   - correlationId     (optional) transaction id to trace execution through call chain.
+//   - filter            (optional) a filter condition with $1, $2... parameters.
+//   - data              a map with fields to be updated.
+//   - args              (optional) values of filter parameters.
+// Returns          updated items or error.
+func (c *IdentifiableJsonPostgresPersistence) UpdateByFilterReturning(correlationId string, filter string, data *cdata.AnyValueMap, args ...interface{}) (items []interface{}, err error) {
+	if data == nil {
+		return nil, nil
+	}
+	setParams, values := c.composeJsonPartialSet(data, args)
+	return c.updateByFilterReturning(correlationId, filter, setParams, values)
+}
+
+// Composes SET clause that merges fields into the data column.
+func (c *IdentifiableJsonPostgresPersistence) composeJsonPartialSet(data *cdata.AnyValueMap, args []interface{}) (string, []interface{}) {
+	values := append(append([]interface{}{}, args...), data.Value())
+	return "\"data\"=\"data\"||$" + strconv.Itoa(len(values)), values
+}
@@ -3,10 +3,15 @@ package persistence
 import (
 	"context"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 
+	"github.com/jackc/pgx/v4"
+	cconf "github.com/pip-services3-go/pip-services3-commons-go/config"
 	cconv "github.com/pip-services3-go/pip-services3-commons-go/convert"
 	cdata "github.com/pip-services3-go/pip-services3-commons-go/data"
+	cerr "github.com/pip-services3-go/pip-services3-commons-go/errors"
 	cmpersist "github.com/pip-services3-go/pip-services3-data-go/persistence"
 )
 
@@ -38,6 +43,12 @@ accessing c._collection and c._model properties.
    - connect_timeout:      (optional) number of milliseconds to wait before timing out when connecting a new client (default: 0)
    - idle_timeout:         (optional) number of milliseconds a client must sit idle in the pool and not be checked out (default: 10000)
    - max_pool_size:        (optional) maximum number of clients the pool should contain (default: 10)
+   - id_column:            (optional) name of the column with unique ids (default: "id")
+   - id_strategy:          (optional) how ids of new items are generated (default: "default"):
+                           "default" - IdGenerator for string ids, database for integer ids,
+                           "uuid4", "uuid7" or "ulid" - string ids generated on client,
+                           "identity" or "gen_random_uuid" - ids generated by IDENTITY, serial or
+                           gen_random_uuid() column default and read back from RETURNING
 
 ### References ###
 
@@ -96,6 +107,14 @@ accessing c._collection and c._model properties.
 */
 type IdentifiablePostgresPersistence struct {
 	*PostgresPersistence
+	//The name of the column with unique ids.
+	IdColumn string
+	//The strategy to generate ids of new items.
+	IdStrategy string
+	idType     reflect.Type
+	// The JSON column that keeps a copy of the id and the key of the copy in it
+	idCopyColumn string
+	idCopyKey    string
 }
 
 // Creates a new instance of the persistence component.
@@ -108,19 +127,86 @@ func InheritIdentifiablePostgresPersistence(overrides IPostgresPersistenceOverri
 
 	c := &IdentifiablePostgresPersistence{}
 	c.PostgresPersistence = InheritPostgresPersistence(overrides, proto, tableName)
+	c.IdColumn = "id"
+	c.IdStrategy = IdStrategyDefault
+	c.idType = idFieldType(proto)
 
 	return c
 }
 
+// Configures component by passing configuration parameters.
+//   - config    configuration parameters to be set.
+func (c *IdentifiablePostgresPersistence) Configure(config *cconf.ConfigParams) {
+	c.PostgresPersistence.Configure(config)
+
+	c.IdColumn = config.GetAsStringWithDefault("options.id_column", c.IdColumn)
+	c.IdStrategy = config.GetAsStringWithDefault("options.id_strategy", c.IdStrategy)
+}
+
+// Opens the component.
+//   - correlationId 	(optional) transaction id to trace execution through call chain.
+// Returns 			 error or nil no errors occured.
+func (c *IdentifiablePostgresPersistence) Open(correlationId string) error {
+	if !isValidIdStrategy(c.IdStrategy) {
+		return cerr.NewConfigError(correlationId, "INVALID_ID_STRATEGY",
+			"Unknown id strategy "+c.IdStrategy).
+			WithDetails("strategy", c.IdStrategy)
+	}
+	return c.PostgresPersistence.Open(correlationId)
+}
+
+// Checks if ids of new items are left to the database.
+func (c *IdentifiablePostgresPersistence) isIdGeneratedByDatabase() bool {
+	switch c.IdStrategy {
+	case IdStrategyIdentity, IdStrategyRandomUuid:
+		return true
+	case IdStrategyDefault:
+		return c.idType != nil && isNumberKind(c.idType.Kind())
+	}
+	return false
+}
+
+// Assigns an id to a copy of the item and converts it into a row.
+// The empty id column is removed when the id is generated by the database.
+func (c *IdentifiablePostgresPersistence) prepareRow(correlationId string, item interface{}) (map[string]interface{}, error) {
+	newItem := cmpersist.CloneObject(item, c.Prototype)
+	if err := generateId(correlationId, c.IdStrategy, c.idType, &newItem); err != nil {
+		return nil, err
+	}
+
+	row := c.convertToMap(c.Overrides.ConvertFromPublic(newItem))
+	if row == nil {
+		return nil, cerr.NewInternalError(correlationId, "CONVERSION_FAILED",
+			"Failed to convert item to a row of "+c.QuotedTableName())
+	}
+	if c.isIdGeneratedByDatabase() && isEmptyId(row[c.IdColumn]) {
+		delete(row, c.IdColumn)
+	}
+	return row, nil
+}
+
+// Gets the id from the id column of the row converted from the item.
+// The id of the item is used when the row is not a map or a struct.
+func (c *IdentifiablePostgresPersistence) rowId(row interface{}, item interface{}) interface{} {
+	if values := c.convertToMap(row); values != nil {
+		return values[c.IdColumn]
+	}
+	return cmpersist.GetObjectId(item)
+}
+
 // Gets a list of data items retrieved by given unique ids.
 //   - correlationId     (optional) transaction id to trace execution through call chain.
 //   - ids               ids of data items to be retrieved
 // Returns          a data list or error.
 func (c *IdentifiablePostgresPersistence) GetListByIds(correlationId string, ids []interface{}) (items []interface{}, err error) {
+	if len(ids) == 0 {
+		return make([]interface{}, 0), nil
+	}
+
 	params := c.GenerateParameters(ids)
-	query := "SELECT * FROM " + c.QuotedTableName() + " WHERE \"id\" IN(" + params + ")"
+	query := "SELECT * FROM " + c.QuotedTableName() + " WHERE " + c.QuoteIdentifier(c.IdColumn) + " IN(" + params + ")"
 
-	qResult, qErr := c.Client.Query(context.TODO(), query, ids...)
+	qResult, qErr := c.ReadQuery(context.TODO(), correlationId, query, ids...)
 	if qErr != nil {
 		return nil, qErr
 	}
@@ -145,9 +231,9 @@ func (c *IdentifiablePostgresPersistence) GetListByIds(correlationId string, ids
 // Returns           data item or error.
 func (c *IdentifiablePostgresPersistence) GetOneById(correlationId string, id interface{}) (item interface{}, err error) {
 
-	query := "SELECT * FROM " + c.QuotedTableName() + " WHERE \"id\"=$1"
+	query := "SELECT * FROM " + c.QuotedTableName() + " WHERE " + c.QuoteIdentifier(c.IdColumn) + "=$1"
 
-	qResult, qErr := c.Client.Query(context.TODO(), query, id)
+	qResult, qErr := c.ReadQuery(context.TODO(), correlationId, query, id)
 	if qErr != nil {
 		return nil, qErr
 	}
@@ -168,6 +254,86 @@ func (c *IdentifiablePostgresPersistence) GetOneById(correlationId string, id in
 	return nil, vErr
 }
 
+// Gets a data item by its unique id and locks its row until the end of the transaction.
+//   - correlationId     (optional) transaction id to trace execution through call chain.
+//   - tx                a transaction to lock the row in.
+//   - id                an id of data item to be retrieved.
+//   - options           (optional) lock options, by default FOR UPDATE.
+// Returns data item, nil if it was not found or is skipped as locked, or error.
+func (c *IdentifiablePostgresPersistence) GetOneByIdLocked(correlationId string, tx pgx.Tx, id interface{},
+	options *PostgresLockOptions) (item interface{}, err error) {
+
+	query := "SELECT * FROM " + c.QuotedTableName() + " WHERE " + c.QuoteIdentifier(c.IdColumn) + "=$1"
+
+	items, err := c.readLocked(correlationId, tx, query, options, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		c.Logger.Trace(correlationId, "Nothing found from %s with id = %s", c.TableName, id)
+		return nil, nil
+	}
+	c.Logger.Trace(correlationId, "Retrieved and locked from %s with id = %s", c.TableName, id)
+	return items[0], nil
+}
+
+// Gets a list of data items by their unique ids and locks their rows until the end of the transaction.
+//   - correlationId     (optional) transaction id to trace execution through call chain.
+//   - tx                a transaction to lock the rows in.
+//   - ids               ids of data items to be retrieved.
+//   - options           (optional) lock options, by default FOR UPDATE.
+// Returns data list or error.
+func (c *IdentifiablePostgresPersistence) GetListByIdsLocked(correlationId string, tx pgx.Tx, ids []interface{},
+	options *PostgresLockOptions) (items []interface{}, err error) {
+
+	if len(ids) == 0 {
+		return make([]interface{}, 0), nil
+	}
+
+	params := c.GenerateParameters(ids)
+	query := "SELECT * FROM " + c.QuotedTableName() + " WHERE " + c.QuoteIdentifier(c.IdColumn) + " IN(" + params + ")"
+
+	items, err = c.readLocked(correlationId, tx, query, options, ids...)
+	if err != nil {
+		return nil, err
+	}
+	c.Logger.Trace(correlationId, "Retrieved and locked %d from %s", len(items), c.TableName)
+	return items, nil
+}
+
+// Gets a list of data items retrieved by a given filter and locks their rows until the end of the transaction.
+// With LockSkipLocked and a limit it claims the next rows that are not locked by other transactions,
+// so several workers can distribute work from the same table.
+//   - correlationId     (optional) transaction id to trace execution through call chain.
+//   - tx                a transaction to lock the rows in.
+//   - filter            (optional) a filter condition with $1, $2... parameters.
+//   - sort              (optional) sorting expression.
+//   - limit             maximum number of items to retrieve, 0 for all matching items.
+//   - options           (optional) lock options, by default FOR UPDATE.
+//   - args              filter arguments.
+// Returns data list or error.
+func (c *IdentifiablePostgresPersistence) GetListByFilterLocked(correlationId string, tx pgx.Tx, filter string,
+	sort string, limit int64, options *PostgresLockOptions, args ...interface{}) (items []interface{}, err error) {
+
+	query := "SELECT * FROM " + c.QuotedTableName()
+	if filter != "" {
+		query += " WHERE " + filter
+	}
+	if sort != "" {
+		query += " ORDER BY " + sort
+	}
+	if limit > 0 {
+		query += " LIMIT " + strconv.FormatInt(limit, 10)
+	}
+
+	items, err = c.readLocked(correlationId, tx, query, options, args...)
+	if err != nil {
+		return nil, err
+	}
+	c.Logger.Trace(correlationId, "Retrieved and locked %d from %s", len(items), c.TableName)
+	return items, nil
+}
+
 // Creates a data item.
 //   - correlation_id    (optional) transaction id to trace execution through call chain.
 //   - item              an item to be created.
@@ -176,12 +342,53 @@ func (c *IdentifiablePostgresPersistence) Create(correlationId string, item inte
 	if item == nil {
 		return nil, nil
 	}
-	// Assign unique id
-	var newItem interface{}
-	newItem = cmpersist.CloneObject(item, c.Prototype)
-	cmpersist.GenerateObjectId(&newItem)
+	row, err := c.prepareRow(correlationId, item)
+	if err != nil {
+		return nil, err
+	}
 
-	return c.PostgresPersistence.Create(correlationId, newItem)
+	columns, source, values := c.composeInsert(row)
+	return c.insert(correlationId, columns, source, values)
+}
+
+// Composes an expression that sets the id into the JSON value, like: jsonb_set("data",'{"id"}',to_jsonb("id"))
+func (c *IdentifiablePostgresPersistence) composeIdCopy(value string, id string) string {
+	key := strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "'", "''").Replace(c.idCopyKey)
+	return "jsonb_set(" + value + ",'{\"" + key + "\"}',to_jsonb(" + id + "))"
+}
+
+// Composes columns and source of INSERT statement for the row, like: VALUES ($1,$2)
+// When the id is generated by the database and the id is copied into a JSON column,
+// the id is generated first, so both columns get the same value in one statement:
+// OVERRIDING SYSTEM VALUE SELECT "v"."id",jsonb_set($1::jsonb,'{"id"}',to_jsonb("v"."id")) FROM (SELECT nextval(...) AS "id") AS "v"
+func (c *IdentifiablePostgresPersistence) composeInsert(row map[string]interface{}) (columns string, source string, values []interface{}) {
+	columns = c.GenerateColumns(row)
+	values = c.GenerateValues(columns, row)
+	if _, ok := row[c.IdColumn]; ok || c.idCopyColumn == "" {
+		return columns, "VALUES (" + c.GenerateParameters(row) + ")", values
+	}
+
+	var idExpr string
+	if c.IdStrategy == IdStrategyRandomUuid {
+		idExpr = "gen_random_uuid()"
+	} else {
+		values = append(values, c.QuotedTableName(), c.IdColumn)
+		idExpr = "nextval(pg_get_serial_sequence($" + strconv.Itoa(len(values)-1) + ",$" + strconv.Itoa(len(values)) + "))"
+	}
+
+	id := "\"v\"." + c.QuoteIdentifier(c.IdColumn)
+	fields := []string{id}
+	for index, column := range strings.Split(columns, ",") {
+		param := "$" + strconv.Itoa(index+1)
+		if column == c.QuoteIdentifier(c.idCopyColumn) {
+			param = c.composeIdCopy(param+"::jsonb", id)
+		}
+		fields = append(fields, param)
+	}
+	columns = c.QuoteIdentifier(c.IdColumn) + "," + columns
+	source = "OVERRIDING SYSTEM VALUE SELECT " + strings.Join(fields, ",") +
+		" FROM (SELECT " + idExpr + " AS " + c.QuoteIdentifier(c.IdColumn) + ") AS \"v\""
+	return columns, source, values
 }
 
 // Sets a data item. If the data item exists it updates it,
@@ -190,27 +397,84 @@ func (c *IdentifiablePostgresPersistence) Create(correlationId string, item inte
 //   - item              a item to be set.
 // Returns          (optional)  updated item or error.
 func (c *IdentifiablePostgresPersistence) Set(correlationId string, item interface{}) (result interface{}, err error) {
+	return c.Upsert(correlationId, item, nil, nil)
+}
+
+// Generates a conflict target like: "column1","column2"
+// Expressions in parentheses, like (data->'key'), are kept as is to match unique expression indexes.
+func (c *IdentifiablePostgresPersistence) composeConflictTarget(columns []string) string {
+	target := make([]string, len(columns))
+	for index, column := range columns {
+		if strings.HasPrefix(column, "(") {
+			target[index] = column
+		} else {
+			target[index] = c.QuoteIdentifier(column)
+		}
+	}
+	return strings.Join(target, ",")
+}
+
+// Inserts a data item or updates the existing one that conflicts with it on the given unique columns.
+//   - correlation_id    (optional) transaction id to trace execution through call chain.
+//   - item              an item to be set.
+//   - conflictColumns   (optional) columns or expressions of a unique index to detect conflicts (default: id column).
+//   - updateColumns     (optional) columns to update on conflict. By default all columns are updated
+//                       except conflict columns and the id column that keep values of the existing row.
+// Returns          (optional)  inserted or updated item or error. Nothing is returned when there are no columns to update.
+func (c *IdentifiablePostgresPersistence) Upsert(correlationId string, item interface{},
+	conflictColumns []string, updateColumns []string) (result interface{}, err error) {
 
 	if item == nil {
 		return nil, nil
 	}
 
-	// Assign unique id
-	var newItem interface{}
-	newItem = cmpersist.CloneObject(item, c.Prototype)
-	cmpersist.GenerateObjectId(&newItem)
+	row, err := c.prepareRow(correlationId, item)
+	if err != nil {
+		return nil, err
+	}
+	if len(conflictColumns) == 0 {
+		conflictColumns = []string{c.IdColumn}
+	}
+	if updateColumns == nil {
+		updateColumns = make([]string, 0, len(row))
+		for column := range row {
+			keep := column == c.IdColumn
+			for _, conflictColumn := range conflictColumns {
+				keep = keep || column == conflictColumn
+			}
+			if !keep {
+				updateColumns = append(updateColumns, column)
+			}
+		}
+		sort.Strings(updateColumns)
+	}
 
-	row := c.Overrides.ConvertFromPublic(item)
-	params := c.GenerateParameters(row)
-	setParams, columns := c.GenerateSetParameters(row)
-	values := c.GenerateValues(columns, row)
-	id := cmpersist.GetObjectId(newItem)
+	columns, source, values := c.composeInsert(row)
+
+	action := "DO NOTHING"
+	if len(updateColumns) > 0 {
+		// The copy of the id in JSON data keeps the id of the updated row
+		id := c.QuotedTableName() + "." + c.QuoteIdentifier(c.IdColumn)
+		setParams := make([]string, len(updateColumns))
+		for _, column := range updateColumns {
+			if column == c.IdColumn {
+				id = "EXCLUDED." + c.QuoteIdentifier(c.IdColumn)
+			}
+		}
+		for index, column := range updateColumns {
+			value := "EXCLUDED." + c.QuoteIdentifier(column)
+			if column == c.idCopyColumn && c.idCopyColumn != "" {
+				value = c.composeIdCopy(value, id)
+			}
+			setParams[index] = c.QuoteIdentifier(column) + "=" + value
+		}
+		action = "DO UPDATE SET " + strings.Join(setParams, ",")
+	}
 
-	query := "INSERT INTO " + c.QuotedTableName() + " (" + columns + ")" +
-		" VALUES (" + params + ")" +
-		" ON CONFLICT (\"id\") DO UPDATE SET " + setParams + " RETURNING *"
+	query := "INSERT INTO " + c.QuotedTableName() + " (" + columns + ") " + source +
+		" ON CONFLICT (" + c.composeConflictTarget(conflictColumns) + ") " + action + " RETURNING *"
 
-	qResult, qErr := c.Client.Query(context.TODO(), query, values...)
+	qResult, qErr := c.Query(context.TODO(), correlationId, query, values...)
 	if qErr != nil {
 		return nil, qErr
 	}
@@ -219,14 +483,50 @@ func (c *IdentifiablePostgresPersistence) Set(correlationId string, item interfa
 	if !qResult.Next() {
 		return nil, qResult.Err()
 	}
-	rows, vErr := qResult.Values()
-	if vErr == nil && len(rows) > 0 {
-		result := c.Overrides.ConvertToPublic(qResult)
-		c.Logger.Trace(correlationId, "Set in %s with id = %s", c.TableName, id)
-		return result, nil
+	result = c.Overrides.ConvertToPublic(qResult)
+	c.Logger.Trace(correlationId, "Set in %s with id = %s", c.TableName, cmpersist.GetObjectId(result))
+	return result, nil
+}
+
+// Creates a data item unless it conflicts with an existing one.
+//   - correlation_id    (optional) transaction id to trace execution through call chain.
+//   - item              an item to be created.
+//   - conflictColumns   (optional) columns or expressions of a unique index to detect conflicts.
+//                       By default a conflict on any unique index or the primary key skips the item.
+// Returns          created item and true, or nil and false when the item already exists, or error.
+func (c *IdentifiablePostgresPersistence) CreateIfNotExists(correlationId string, item interface{},
+	conflictColumns []string) (result interface{}, created bool, err error) {
+
+	if item == nil {
+		return nil, false, nil
 	}
-	return nil, vErr
 
+	row, err := c.prepareRow(correlationId, item)
+	if err != nil {
+		return nil, false, err
+	}
+	columns, source, values := c.composeInsert(row)
+
+	target := ""
+	if len(conflictColumns) > 0 {
+		target = "(" + c.composeConflictTarget(conflictColumns) + ") "
+	}
+	query := "INSERT INTO " + c.QuotedTableName() + " (" + columns + ") " + source +
+		" ON CONFLICT " + target + "DO NOTHING RETURNING *"
+
+	qResult, qErr := c.Query(context.TODO(), correlationId, query, values...)
+	if qErr != nil {
+		return nil, false, qErr
+	}
+	defer qResult.Close()
+
+	if !qResult.Next() {
+		c.Logger.Trace(correlationId, "Skipped existing item in %s", c.TableName)
+		return nil, false, qResult.Err()
+	}
+	result = c.Overrides.ConvertToPublic(qResult)
+	c.Logger.Trace(correlationId, "Created in %s with id = %s", c.TableName, cmpersist.GetObjectId(result))
+	return result, true, nil
 }
 
 // Updates a data item.
@@ -240,17 +540,17 @@ func (c *IdentifiablePostgresPersistence) Update(correlationId string, item inte
 	}
 	var newItem interface{}
 	newItem = cmpersist.CloneObject(item, c.Prototype)
-	id := cmpersist.GetObjectId(newItem)
 
 	row := c.Overrides.ConvertFromPublic(newItem)
+	id := c.rowId(row, newItem)
 	params, col := c.GenerateSetParameters(row)
 	values := c.GenerateValues(col, row)
 	values = append(values, id)
 
 	query := "UPDATE " + c.QuotedTableName() +
-		" SET " + params + " WHERE \"id\"=$" + strconv.FormatInt((int64)(len(values)), 10) + " RETURNING *"
+		" SET " + params + " WHERE " + c.QuoteIdentifier(c.IdColumn) + "=$" + strconv.FormatInt((int64)(len(values)), 10) + " RETURNING *"
 
-	qResult, qErr := c.Client.Query(context.TODO(), query, values...)
+	qResult, qErr := c.Query(context.TODO(), correlationId, query, values...)
 
 	if qErr != nil {
 		return nil, qErr
@@ -285,9 +585,9 @@ func (c *IdentifiablePostgresPersistence) UpdatePartially(correlationId string,
 	values = append(values, id)
 
 	query := "UPDATE " + c.QuotedTableName() +
-		" SET " + params + " WHERE \"id\"=$" + strconv.FormatInt((int64)(len(values)), 10) + " RETURNING *"
+		" SET " + params + " WHERE " + c.QuoteIdentifier(c.IdColumn) + "=$" + strconv.FormatInt((int64)(len(values)), 10) + " RETURNING *"
 
-	qResult, qErr := c.Client.Query(context.TODO(), query, values...)
+	qResult, qErr := c.Query(context.TODO(), correlationId, query, values...)
 
 	if qErr != nil {
 		return nil, qErr
@@ -305,15 +605,130 @@ func (c *IdentifiablePostgresPersistence) UpdatePartially(correlationId string,
 	return nil, vErr
 }
 
+// Updates a data item only if the condition holds for the stored item, like status='pending'.
+//   - correlation_id    (optional) transaction id to trace execution through call chain.
+//   - item              an item to be updated.
+//   - condition         a condition on the stored item with $1, $2... parameters.
+//   - args              (optional) values of condition parameters.
+// Returns          updated item, NotFoundError with NOT_FOUND code when the item does not exist,
+//                  ConflictError with CONDITION_FAILED code when the condition does not hold, or other error.
+func (c *IdentifiablePostgresPersistence) UpdateIf(correlationId string, item interface{},
+	condition string, args ...interface{}) (result interface{}, err error) {
+
+	if item == nil {
+		return nil, nil
+	}
+	newItem := cmpersist.CloneObject(item, c.Prototype)
+
+	row := c.Overrides.ConvertFromPublic(newItem)
+	id := c.rowId(row, newItem)
+	setParams, columns := c.generateSetParameters(row, len(args))
+	values := append(append([]interface{}{}, args...), c.GenerateValues(columns, row)...)
+
+	return c.updateIf(correlationId, id, condition, setParams, values)
+}
+
+// Updates only few selected fields in a data item only if the condition holds for the stored item.
+//   - correlation_id    (optional) transaction id to trace execution through call chain.
+//   - id                an id of data item to be updated.
+//   - data              a map with fields to be updated.
+//   - condition         a condition on the stored item with $1, $2... parameters.
+//   - args              (optional) values of condition parameters.
+// Returns          updated item, NotFoundError with NOT_FOUND code when the item does not exist,
+//                  ConflictError with CONDITION_FAILED code when the condition does not hold, or other error.
+func (c *IdentifiablePostgresPersistence) UpdatePartiallyIf(correlationId string, id interface{}, data *cdata.AnyValueMap,
+	condition string, args ...interface{}) (result interface{}, err error) {
+
+	if id == nil || data == nil {
+		return nil, nil
+	}
+	setParams, values := c.composePartialSet(data, args)
+	return c.updateIf(correlationId, id, condition, setParams, values)
+}
+
+// Updates the item with the id if the condition holds and tells a missing item from a failed condition.
+//   - values    values of condition parameters followed by values of set parameters.
+func (c *IdentifiablePostgresPersistence) updateIf(correlationId string, id interface{}, condition string,
+	setParams string, values []interface{}) (result interface{}, err error) {
+
+	values = append(values, id)
+	filter := c.QuoteIdentifier(c.IdColumn) + "=$" + strconv.Itoa(len(values))
+	if condition != "" {
+		filter += " AND (" + condition + ")"
+	}
+
+	qResult, qErr := c.Query(context.TODO(), correlationId, c.composeUpdateByFilter(filter, setParams, true), values...)
+	if qErr != nil {
+		return nil, qErr
+	}
+	defer qResult.Close()
+	if qResult.Next() {
+		result = c.Overrides.ConvertToPublic(qResult)
+		c.Logger.Trace(correlationId, "Updated conditionally in %s with id = %s", c.TableName, id)
+		return result, nil
+	}
+	if err = qResult.Err(); err != nil {
+		return nil, err
+	}
+	qResult.Close()
+
+	// Nothing was updated, find out why
+	exists := false
+	query := "SELECT EXISTS(SELECT 1 FROM " + c.QuotedTableName() + " WHERE " + c.QuoteIdentifier(c.IdColumn) + "=$1)"
+	eResult, eErr := c.Query(context.TODO(), correlationId, query, id)
+	if eErr != nil {
+		return nil, eErr
+	}
+	defer eResult.Close()
+	if eResult.Next() {
+		if err = eResult.Scan(&exists); err != nil {
+			return nil, err
+		}
+	}
+	if err = eResult.Err(); err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, cerr.NewNotFoundError(correlationId, "NOT_FOUND",
+			"Item with id "+cconv.StringConverter.ToString(id)+" was not found in "+c.TableName).
+			WithDetails("id", id)
+	}
+	return nil, cerr.NewConflictError(correlationId, "CONDITION_FAILED",
+		"Update condition failed for item with id "+cconv.StringConverter.ToString(id)+" in "+c.TableName).
+		WithDetails("id", id).
+		WithDetails("condition", condition)
+}
+
+// Updates a data item by atomic operations like increments or array appends
+// in one statement without reading the item first.
+//   - correlation_id    (optional) transaction id to trace execution through call chain.
+//   - id                an id of data item to be updated.
+//   - operations        operations to apply to columns of the item.
+// Returns           updated item or error.
+func (c *IdentifiablePostgresPersistence) UpdateByOperations(correlationId string, id interface{},
+	operations *PostgresUpdateOperations) (result interface{}, err error) {
+
+	if id == nil || operations == nil || operations.Len() == 0 {
+		return nil, nil
+	}
+
+	result, err = c.updateByOperations(correlationId, c.QuoteIdentifier(c.IdColumn)+"=$1", []interface{}{id}, operations)
+	if err == nil && result != nil {
+		c.Logger.Trace(correlationId, "Updated by operations in %s with id = %s", c.TableName, id)
+	}
+	return result, err
+}
+
 // Deleted a data item by it's unique id.
 //   - correlation_id    (optional) transaction id to trace execution through call chain.
 //   - id                an id of the item to be deleted
 // Returns          (optional)  deleted item or error.
 func (c *IdentifiablePostgresPersistence) DeleteById(correlationId string, id interface{}) (result interface{}, err error) {
 
-	query := "DELETE FROM " + c.QuotedTableName() + " WHERE \"id\"=$1 RETURNING *"
+	query := "DELETE FROM " + c.QuotedTableName() + " WHERE " + c.QuoteIdentifier(c.IdColumn) + "=$1 RETURNING *"
 
-	qResult, qErr := c.Client.Query(context.TODO(), query, id)
+	qResult, qErr := c.Query(context.TODO(), correlationId, query, id)
 
 	if qErr != nil {
 		return nil, qErr
@@ -334,28 +749,25 @@ func (c *IdentifiablePostgresPersistence) DeleteById(correlationId string, id in
 // Deletes multiple data items by their unique ids.
 //   - correlationId     (optional) transaction id to trace execution through call chain.
 //   - ids               ids of data items to be deleted.
-// Returns          (optional)  error or null for success.
-func (c *IdentifiablePostgresPersistence) DeleteByIds(correlationId string, ids []interface{}) error {
+// Returns          number of deleted items or error.
+func (c *IdentifiablePostgresPersistence) DeleteByIds(correlationId string, ids []interface{}) (count int64, err error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
 
 	params := c.GenerateParameters(ids)
-	query := "DELETE FROM " + c.QuotedTableName() + " WHERE \"id\" IN(" + params + ")"
-
-	qResult, qErr := c.Client.Query(context.TODO(), query, ids...)
+	query := "DELETE FROM " + c.QuotedTableName() + " WHERE " + c.QuoteIdentifier(c.IdColumn) + " IN(" + params + ")"
 
+	qResult, qErr := c.Query(context.TODO(), correlationId, query, ids...)
 	if qErr != nil {
-		return qErr
+		return 0, qErr
 	}
-	defer qResult.Close()
-	if !qResult.Next() {
-		return qResult.Err()
+	count, err = c.readAffectedCount(qResult)
+	if err != nil {
+		return 0, err
 	}
-	var count int64 = 0
-	rows, vErr := qResult.Values()
-	if vErr == nil && len(rows) == 1 {
-		count = cconv.LongConverter.ToLong(rows[0])
-		if count != 0 {
-			c.Logger.Trace(correlationId, "Deleted %d items from %s", count, c.TableName)
-		}
+	if count != 0 {
+		c.Logger.Trace(correlationId, "Deleted %d items from %s", count, c.TableName)
 	}
-	return vErr
+	return count, nil
 }
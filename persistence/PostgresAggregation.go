@@ -0,0 +1,280 @@
+package persistence
+
+import (
+	"strconv"
+	"strings"
+
+	cerr "github.com/pip-services3-go/pip-services3-commons-go/errors"
+)
+
+// Kinds of aggregation fields
+const (
+	aggregateGroup      = "group"
+	aggregateTime       = "time"
+	aggregateCount      = "count"
+	aggregateDistinct   = "distinct"
+	aggregateSum        = "sum"
+	aggregateAvg        = "avg"
+	aggregateMin        = "min"
+	aggregateMax        = "max"
+	aggregatePercentile = "percentile"
+)
+
+// Units of time buckets accepted by date_trunc
+var aggregateTimeUnits = map[string]bool{
+	"microseconds": true, "milliseconds": true, "second": true, "minute": true, "hour": true,
+	"day": true, "week": true, "month": true, "quarter": true, "year": true,
+	"decade": true, "century": true, "millennium": true,
+}
+
+type postgresAggregateField struct {
+	kind     string
+	alias    string
+	column   string
+	path     []string
+	unit     string
+	fraction float64
+}
+
+/*
+Group-by fields and aggregate expressions compiled into one parameterized SELECT statement
+by PostgresPersistence.Aggregate.
+
+Fields without a path use the column itself. Fields with a path use a value inside a JSONB column,
+like a field in the data column of IdentifiableJsonPostgresPersistence: groups use its text,
+and aggregates use its numeric value. Result columns are named by aliases,
+that can be used in the having condition and sorting.
+
+### Example ###
+
+    aggregation := persist.NewPostgresAggregation().
+        GroupByTime("day", "created", "day").
+        GroupByPath("color", "data", []string{"options", "color"}).
+        Count("count").
+        Sum("total", "amount").
+        Percentile("p90", "amount", 0.9).
+        Having("count > $2")
+
+    items, err := persistence.Aggregate("123", "amount > $1", aggregation, "total DESC", reflect.TypeOf(Report{}), 10, 5)
+*/
+type PostgresAggregation struct {
+	groups     []*postgresAggregateField
+	aggregates []*postgresAggregateField
+	having     string
+}
+
+// Creates a new empty aggregation.
+func NewPostgresAggregation() *PostgresAggregation {
+	return &PostgresAggregation{
+		groups:     make([]*postgresAggregateField, 0),
+		aggregates: make([]*postgresAggregateField, 0),
+	}
+}
+
+func (c *PostgresAggregation) addGroup(field *postgresAggregateField) *PostgresAggregation {
+	c.groups = append(c.groups, field)
+	return c
+}
+
+func (c *PostgresAggregation) addAggregate(field *postgresAggregateField) *PostgresAggregation {
+	c.aggregates = append(c.aggregates, field)
+	return c
+}
+
+// Groups rows by the column. The result column is named after the column.
+func (c *PostgresAggregation) GroupBy(column string) *PostgresAggregation {
+	return c.addGroup(&postgresAggregateField{kind: aggregateGroup, alias: column, column: column})
+}
+
+// Groups rows by the text of the value at the path inside the JSONB column.
+func (c *PostgresAggregation) GroupByPath(alias string, column string, path []string) *PostgresAggregation {
+	return c.addGroup(&postgresAggregateField{kind: aggregateGroup, alias: alias, column: column, path: path})
+}
+
+// Groups rows by the timestamp column truncated with date_trunc to the unit,
+// like "minute", "hour", "day", "week", "month" or "year".
+func (c *PostgresAggregation) GroupByTime(alias string, column string, unit string) *PostgresAggregation {
+	return c.addGroup(&postgresAggregateField{kind: aggregateTime, alias: alias, column: column, unit: unit})
+}
+
+// Counts rows in the group.
+func (c *PostgresAggregation) Count(alias string) *PostgresAggregation {
+	return c.addAggregate(&postgresAggregateField{kind: aggregateCount, alias: alias})
+}
+
+// Counts distinct not null values of the column in the group.
+func (c *PostgresAggregation) CountDistinct(alias string, column string) *PostgresAggregation {
+	return c.addAggregate(&postgresAggregateField{kind: aggregateDistinct, alias: alias, column: column})
+}
+
+// Counts distinct values at the path inside the JSONB column in the group.
+func (c *PostgresAggregation) CountDistinctPath(alias string, column string, path []string) *PostgresAggregation {
+	return c.addAggregate(&postgresAggregateField{kind: aggregateDistinct, alias: alias, column: column, path: path})
+}
+
+// Sums values of the column in the group.
+func (c *PostgresAggregation) Sum(alias string, column string) *PostgresAggregation {
+	return c.addAggregate(&postgresAggregateField{kind: aggregateSum, alias: alias, column: column})
+}
+
+// Sums numbers at the path inside the JSONB column in the group.
+func (c *PostgresAggregation) SumPath(alias string, column string, path []string) *PostgresAggregation {
+	return c.addAggregate(&postgresAggregateField{kind: aggregateSum, alias: alias, column: column, path: path})
+}
+
+// Averages values of the column in the group.
+func (c *PostgresAggregation) Avg(alias string, column string) *PostgresAggregation {
+	return c.addAggregate(&postgresAggregateField{kind: aggregateAvg, alias: alias, column: column})
+}
+
+// Averages numbers at the path inside the JSONB column in the group.
+func (c *PostgresAggregation) AvgPath(alias string, column string, path []string) *PostgresAggregation {
+	return c.addAggregate(&postgresAggregateField{kind: aggregateAvg, alias: alias, column: column, path: path})
+}
+
+// Gets the minimum value of the column in the group.
+func (c *PostgresAggregation) Min(alias string, column string) *PostgresAggregation {
+	return c.addAggregate(&postgresAggregateField{kind: aggregateMin, alias: alias, column: column})
+}
+
+// Gets the minimum number at the path inside the JSONB column in the group.
+func (c *PostgresAggregation) MinPath(alias string, column string, path []string) *PostgresAggregation {
+	return c.addAggregate(&postgresAggregateField{kind: aggregateMin, alias: alias, column: column, path: path})
+}
+
+// Gets the maximum value of the column in the group.
+func (c *PostgresAggregation) Max(alias string, column string) *PostgresAggregation {
+	return c.addAggregate(&postgresAggregateField{kind: aggregateMax, alias: alias, column: column})
+}
+
+// Gets the maximum number at the path inside the JSONB column in the group.
+func (c *PostgresAggregation) MaxPath(alias string, column string, path []string) *PostgresAggregation {
+	return c.addAggregate(&postgresAggregateField{kind: aggregateMax, alias: alias, column: column, path: path})
+}
+
+// Gets the continuous percentile of values of the column in the group.
+// The fraction is between 0 and 1, like 0.5 for the median.
+func (c *PostgresAggregation) Percentile(alias string, column string, fraction float64) *PostgresAggregation {
+	return c.addAggregate(&postgresAggregateField{kind: aggregatePercentile, alias: alias, column: column,
+		fraction: fraction})
+}
+
+// Gets the continuous percentile of numbers at the path inside the JSONB column in the group.
+func (c *PostgresAggregation) PercentilePath(alias string, column string, path []string, fraction float64) *PostgresAggregation {
+	return c.addAggregate(&postgresAggregateField{kind: aggregatePercentile, alias: alias, column: column,
+		path: path, fraction: fraction})
+}
+
+// Sets a condition on aggregated rows like: count > $1
+// The condition refers to result columns by aliases and shares arguments with the filter.
+func (c *PostgresAggregation) Having(condition string) *PostgresAggregation {
+	c.having = condition
+	return c
+}
+
+// Compiles the aggregation into a query like:
+// SELECT * FROM (SELECT date_trunc('day',"created") AS "day",COUNT(*) AS "count" FROM "table" WHERE ... GROUP BY 1) AS "aggregates"
+// WHERE <having> ORDER BY <sort>
+//   - table     a quoted table name
+//   - quote     a function to quote identifiers
+//   - filter    (optional) a filter condition
+//   - sort      (optional) sorting expression
+//   - offset    number of arguments that precede the aggregation parameters
+// Returns the query and values of its parameters.
+func (c *PostgresAggregation) compile(correlationId string, table string, quote func(string) string,
+	filter string, sort string, offset int) (query string, values []interface{}, err error) {
+
+	if len(c.aggregates) == 0 && len(c.groups) == 0 {
+		return "", nil, cerr.NewBadRequestError(correlationId, "NO_AGGREGATES",
+			"Aggregation of "+table+" has no groups or aggregates")
+	}
+
+	values = make([]interface{}, 0)
+	param := func(value interface{}) string {
+		values = append(values, value)
+		return "$" + strconv.Itoa(offset+len(values))
+	}
+
+	aliases := make(map[string]bool, len(c.groups)+len(c.aggregates))
+	fields := make([]string, 0, len(c.groups)+len(c.aggregates))
+	for _, field := range append(append([]*postgresAggregateField{}, c.groups...), c.aggregates...) {
+		if field.alias == "" || aliases[field.alias] {
+			return "", nil, cerr.NewBadRequestError(correlationId, "INVALID_ALIAS",
+				"Aggregation alias "+field.alias+" is empty or duplicated").
+				WithDetails("alias", field.alias)
+		}
+		aliases[field.alias] = true
+
+		expr, err := field.compile(correlationId, quote, param)
+		if err != nil {
+			return "", nil, err
+		}
+		fields = append(fields, expr+" AS "+quote(field.alias))
+	}
+
+	query = "SELECT " + strings.Join(fields, ",") + " FROM " + table
+	if filter != "" {
+		query += " WHERE " + filter
+	}
+	if len(c.groups) > 0 {
+		positions := make([]string, len(c.groups))
+		for index := range c.groups {
+			positions[index] = strconv.Itoa(index + 1)
+		}
+		query += " GROUP BY " + strings.Join(positions, ",")
+	}
+
+	query = "SELECT * FROM (" + query + ") AS " + quote("aggregates")
+	if c.having != "" {
+		query += " WHERE " + c.having
+	}
+	if sort != "" {
+		query += " ORDER BY " + sort
+	}
+	return query, values, nil
+}
+
+// Compiles the field into SQL expression.
+func (c *postgresAggregateField) compile(correlationId string, quote func(string) string,
+	param func(interface{}) string) (string, error) {
+
+	if c.kind == aggregateCount {
+		return "COUNT(*)", nil
+	}
+
+	value := quote(c.column)
+	if len(c.path) > 0 {
+		value = "(" + value + " #>> " + param(c.path) + "::text[])"
+		if c.kind != aggregateGroup && c.kind != aggregateDistinct {
+			value += "::numeric"
+		}
+	}
+
+	switch c.kind {
+	case aggregateTime:
+		if !aggregateTimeUnits[c.unit] {
+			return "", cerr.NewBadRequestError(correlationId, "INVALID_TIME_UNIT",
+				"Time unit "+c.unit+" is not supported").
+				WithDetails("unit", c.unit)
+		}
+		return "date_trunc('" + c.unit + "'," + value + ")", nil
+	case aggregateDistinct:
+		return "COUNT(DISTINCT " + value + ")", nil
+	case aggregateSum:
+		return "SUM(" + value + ")", nil
+	case aggregateAvg:
+		return "AVG(" + value + ")", nil
+	case aggregateMin:
+		return "MIN(" + value + ")", nil
+	case aggregateMax:
+		return "MAX(" + value + ")", nil
+	case aggregatePercentile:
+		if c.fraction < 0 || c.fraction > 1 {
+			return "", cerr.NewBadRequestError(correlationId, "INVALID_FRACTION",
+				"Percentile fraction must be between 0 and 1").
+				WithDetails("fraction", c.fraction)
+		}
+		return "percentile_cont(" + param(c.fraction) + "::float8) WITHIN GROUP (ORDER BY " + value + ")", nil
+	}
+	return value, nil
+}
@@ -0,0 +1,313 @@
+package persistence
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Column of a table mapped to a field of the prototype struct.
+// Column name is taken from the json tag of the field, and column options
+// can be set in the postgres tag separated by semicolons, for example:
+//
+//     Name    string    `json:"name" postgres:"type:VARCHAR(100);notnull;index"`
+//     Created time.Time `json:"created" postgres:"default:now()"`
+//
+// Supported options:
+//   - type:<sql type>    column type instead of the type derived from the field type
+//   - notnull            column does not accept NULL values
+//   - default:<value>    default column value
+//   - unique             column values are unique
+//   - index              column is indexed
+//   - primarykey         column is a primary key. By default "id" column is a primary key
+//   - "-"                field is not stored
+type postgresColumn struct {
+	name         string
+	sqlType      string
+	notNull      bool
+	defaultValue string
+	unique       bool
+	index        bool
+	primaryKey   bool
+	// True if zero value is not stored, like with json omitempty option
+	omitEmpty bool
+	// Index of the field in the prototype struct, including embedded structs
+	fieldIndex []int
+	fieldType  reflect.Type
+}
+
+// Mapping between table columns and fields of a struct type
+type postgresTypeMapping struct {
+	columns []*postgresColumn
+	byName  map[string]*postgresColumn
+}
+
+// Gets SQL column definition like: "name" BIGINT NOT NULL DEFAULT 0
+func (c *postgresColumn) definition(quote func(string) string) string {
+	result := quote(c.name) + " " + c.sqlType
+	if c.primaryKey {
+		result += " PRIMARY KEY"
+	}
+	if c.notNull && !c.primaryKey {
+		result += " NOT NULL"
+	}
+	if c.defaultValue != "" {
+		result += " DEFAULT " + c.defaultValue
+	}
+	if c.unique && !c.primaryKey {
+		result += " UNIQUE"
+	}
+	return result
+}
+
+var postgresMappingCache sync.Map
+
+// Gets columns of the struct type in the order of fields.
+// Returns nil if the type is not a struct or a pointer to a struct.
+func getPostgresColumns(proto reflect.Type) []*postgresColumn {
+	mapping := getPostgresMapping(proto)
+	if mapping == nil {
+		return nil
+	}
+	return mapping.columns
+}
+
+// Gets mapping between columns and fields of the struct type. The result is cached per type.
+// Returns nil if the type is not a struct or a pointer to a struct.
+func getPostgresMapping(proto reflect.Type) *postgresTypeMapping {
+	if proto == nil {
+		return nil
+	}
+	if proto.Kind() == reflect.Ptr {
+		proto = proto.Elem()
+	}
+	if proto.Kind() != reflect.Struct {
+		return nil
+	}
+
+	if mapping, ok := postgresMappingCache.Load(proto); ok {
+		return mapping.(*postgresTypeMapping)
+	}
+
+	columns := make([]*postgresColumn, 0, proto.NumField())
+	columns = collectStructColumns(proto, nil, columns)
+
+	hasPrimaryKey := false
+	for _, column := range columns {
+		hasPrimaryKey = hasPrimaryKey || column.primaryKey
+	}
+	if !hasPrimaryKey {
+		for _, column := range columns {
+			if column.name == "id" {
+				column.primaryKey = true
+			}
+		}
+	}
+
+	mapping := &postgresTypeMapping{
+		columns: columns,
+		byName:  make(map[string]*postgresColumn, len(columns)),
+	}
+	for _, column := range columns {
+		mapping.byName[column.name] = column
+	}
+
+	postgresMappingCache.Store(proto, mapping)
+	return mapping
+}
+
+func collectStructColumns(structType reflect.Type, parentIndex []int, columns []*postgresColumn) []*postgresColumn {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		fieldIndex := append(append([]int{}, parentIndex...), i)
+
+		name := field.Name
+		tagName := ""
+		omitEmpty := false
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			tagOptions := strings.Split(tag, ",")
+			tagName = tagOptions[0]
+			for _, tagOption := range tagOptions[1:] {
+				omitEmpty = omitEmpty || tagOption == "omitempty"
+			}
+		}
+		if tagName == "-" || field.Tag.Get("postgres") == "-" {
+			continue
+		}
+		if tagName != "" {
+			name = tagName
+		} else if field.Anonymous {
+			// Fields of embedded structs are columns of the same table
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				columns = collectStructColumns(embedded, fieldIndex, columns)
+			}
+			continue
+		}
+
+		column := &postgresColumn{
+			name:       name,
+			sqlType:    sqlTypeOf(field.Type),
+			omitEmpty:  omitEmpty,
+			fieldIndex: fieldIndex,
+			fieldType:  field.Type,
+		}
+		parseColumnOptions(field.Tag.Get("postgres"), column)
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+// Parses options of the postgres tag
+func parseColumnOptions(tag string, column *postgresColumn) {
+	for _, option := range strings.Split(tag, ";") {
+		option = strings.TrimSpace(option)
+		key, value := option, ""
+		if index := strings.Index(option, ":"); index >= 0 {
+			key, value = strings.TrimSpace(option[:index]), strings.TrimSpace(option[index+1:])
+		}
+		switch strings.ToLower(key) {
+		case "type":
+			column.sqlType = value
+		case "notnull":
+			column.notNull = true
+		case "default":
+			column.defaultValue = value
+		case "unique":
+			column.unique = true
+		case "index":
+			column.index = true
+		case "primarykey":
+			column.primaryKey = true
+		}
+	}
+}
+
+// Gets columns of the prototype struct with SQL types derived from field types or postgres tags.
+// Returns nil if the prototype is not a struct.
+func (c *PostgresPersistence) prototypeColumns() map[string]string {
+	columns := getPostgresColumns(c.Prototype)
+	if columns == nil {
+		return nil
+	}
+
+	result := make(map[string]string, len(columns))
+	for _, column := range columns {
+		result[column.name] = column.sqlType
+	}
+	return result
+}
+
+// Converts the current row into a new prototype object by assigning column values
+// directly to mapped struct fields.
+// Returns the object, false if the prototype is not a struct, and error if the row can not be converted.
+func (c *PostgresPersistence) convertRowToObject(rows pgx.Rows) (interface{}, bool, error) {
+	return convertRowToStruct(rows, c.Prototype)
+}
+
+// Converts the current row into a new object of the struct type, or a pointer to struct type,
+// by assigning column values to mapped struct fields.
+// Returns the object, false if the type is not a struct, and error if the row can not be converted.
+func convertRowToStruct(rows pgx.Rows, proto reflect.Type) (interface{}, bool, error) {
+	mapping := getPostgresMapping(proto)
+	if mapping == nil {
+		return nil, false, nil
+	}
+	values, err := rows.Values()
+	if err != nil {
+		return nil, true, err
+	}
+	if values == nil {
+		return nil, true, errors.New("row has no values")
+	}
+
+	structType := proto
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	docPointer := reflect.New(structType)
+	doc := docPointer.Elem()
+	for index, field := range rows.FieldDescriptions() {
+		column, ok := mapping.byName[string(field.Name)]
+		if !ok || values[index] == nil {
+			continue
+		}
+		target := fieldByIndex(doc, column.fieldIndex, true)
+		if err = assignColumnValue(target, values[index]); err != nil {
+			return nil, true, fmt.Errorf("column %s: %w", field.Name, err)
+		}
+	}
+	if proto.Kind() == reflect.Ptr {
+		return docPointer.Interface(), true, nil
+	}
+	return doc.Interface(), true, nil
+}
+
+// Converts a struct or a map into a map of column values keeping Go types of fields.
+// Returns the map and false if the value is neither a struct nor a map with string keys.
+func convertObjectToMap(value interface{}) (map[string]interface{}, bool) {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		items := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			items[iter.Key().String()] = iter.Value().Interface()
+		}
+		return items, true
+	case reflect.Struct:
+		mapping := getPostgresMapping(v.Type())
+		items := make(map[string]interface{}, len(mapping.columns))
+		for _, column := range mapping.columns {
+			field := fieldByIndex(v, column.fieldIndex, false)
+			if !field.IsValid() || (column.omitEmpty && field.IsZero()) {
+				continue
+			}
+			item, err := columnValueOf(field)
+			if err != nil {
+				return nil, false
+			}
+			items[column.name] = item
+		}
+		return items, true
+	}
+	return nil, false
+}
+
+// Gets a nested field by index. Nil embedded pointers are allocated when alloc is true,
+// otherwise an invalid value is returned for fields behind nil pointers.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) reflect.Value {
+	for i, fieldIndex := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(fieldIndex)
+	}
+	return v
+}
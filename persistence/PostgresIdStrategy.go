@@ -0,0 +1,161 @@
+package persistence
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"time"
+
+	cerr "github.com/pip-services3-go/pip-services3-commons-go/errors"
+	cmpersist "github.com/pip-services3-go/pip-services3-data-go/persistence"
+)
+
+// Strategies to generate ids of new data items set in options.id_strategy.
+const (
+	// Generates string ids by IdGenerator and leaves integer ids to the database
+	IdStrategyDefault = "default"
+	// Generates random UUID version 4 on client
+	IdStrategyUuid4 = "uuid4"
+	// Generates time ordered UUID version 7 on client
+	IdStrategyUuid7 = "uuid7"
+	// Generates time ordered ULID on client
+	IdStrategyUlid = "ulid"
+	// Leaves ids to IDENTITY or serial column, generated id is read back from RETURNING
+	IdStrategyIdentity = "identity"
+	// Leaves ids to gen_random_uuid() column default, generated id is read back from RETURNING
+	IdStrategyRandomUuid = "gen_random_uuid"
+)
+
+// Crockford's base32 alphabet used by ULID
+const ulidAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// Checks if the strategy name is known.
+func isValidIdStrategy(strategy string) bool {
+	switch strategy {
+	case IdStrategyDefault, IdStrategyUuid4, IdStrategyUuid7, IdStrategyUlid,
+		IdStrategyIdentity, IdStrategyRandomUuid:
+		return true
+	}
+	return false
+}
+
+// Finds the Id field in the prototype struct.
+func idField(proto reflect.Type) (reflect.StructField, bool) {
+	for proto != nil && proto.Kind() == reflect.Ptr {
+		proto = proto.Elem()
+	}
+	if proto == nil || proto.Kind() != reflect.Struct {
+		return reflect.StructField{}, false
+	}
+	return proto.FieldByNameFunc(func(name string) bool {
+		return strings.EqualFold(name, "id")
+	})
+}
+
+// Finds type of the Id field in the prototype struct.
+// Returns nil if the prototype has no such field.
+func idFieldType(proto reflect.Type) reflect.Type {
+	field, ok := idField(proto)
+	if !ok {
+		return nil
+	}
+	return field.Type
+}
+
+// Gets the JSON name of the Id field in the prototype struct (default: "id").
+func idFieldJsonName(proto reflect.Type) string {
+	field, ok := idField(proto)
+	if !ok {
+		return "id"
+	}
+	if name := strings.Split(field.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+		return name
+	}
+	return field.Name
+}
+
+// Checks if id value is not set.
+func isEmptyId(id interface{}) bool {
+	if id == nil {
+		return true
+	}
+	return reflect.ValueOf(id).IsZero()
+}
+
+// Assigns a new id to the item when it has no id according to the strategy.
+// Ids generated by the database are left empty.
+func generateId(correlationId string, strategy string, idType reflect.Type, item *interface{}) error {
+	if !isEmptyId(cmpersist.GetObjectId(*item)) {
+		return nil
+	}
+
+	var id string
+	switch strategy {
+	case IdStrategyIdentity, IdStrategyRandomUuid:
+		return nil
+	case IdStrategyUuid4:
+		id = newUuid4()
+	case IdStrategyUuid7:
+		id = newUuid7()
+	case IdStrategyUlid:
+		id = newUlid()
+	default:
+		// Integer ids can only be generated by the database
+		if idType != nil && isNumberKind(idType.Kind()) {
+			return nil
+		}
+		cmpersist.GenerateObjectId(item)
+		return nil
+	}
+
+	if idType != nil && idType.Kind() != reflect.String {
+		return cerr.NewConfigError(correlationId, "INVALID_ID_TYPE",
+			"Id strategy "+strategy+" requires string ids").
+			WithDetails("type", idType.String())
+	}
+	cmpersist.SetObjectId(item, id)
+	return nil
+}
+
+// Generates a random UUID version 4.
+func newUuid4() string {
+	var uuid [16]byte
+	rand.Read(uuid[:])
+	uuid[6] = (uuid[6] & 0x0f) | 0x40
+	uuid[8] = (uuid[8] & 0x3f) | 0x80
+	return formatUUID(uuid)
+}
+
+// Generates UUID version 7 that starts with 48 bits of unix time in milliseconds.
+func newUuid7() string {
+	var uuid [16]byte
+	rand.Read(uuid[6:])
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(time.Now().UnixNano()/int64(time.Millisecond)))
+	copy(uuid[:6], ms[2:])
+	uuid[6] = (uuid[6] & 0x0f) | 0x70
+	uuid[8] = (uuid[8] & 0x3f) | 0x80
+	return formatUUID(uuid)
+}
+
+// Generates ULID: 48 bits of unix time in milliseconds and 80 random bits
+// encoded into 26 characters of Crockford's base32.
+func newUlid() string {
+	var ulid [16]byte
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(time.Now().UnixNano()/int64(time.Millisecond)))
+	copy(ulid[:6], ms[2:])
+	rand.Read(ulid[6:])
+
+	// 128 bits are encoded from the lowest 5 bits, the first character takes the remaining 3 bits
+	hi := binary.BigEndian.Uint64(ulid[:8])
+	lo := binary.BigEndian.Uint64(ulid[8:])
+	result := make([]byte, 26)
+	for i := 25; i >= 0; i-- {
+		result[i] = ulidAlphabet[lo&0x1f]
+		lo = (lo >> 5) | (hi << 59)
+		hi >>= 5
+	}
+	return string(result)
+}
@@ -0,0 +1,146 @@
+package persistence
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	cerr "github.com/pip-services3-go/pip-services3-commons-go/errors"
+)
+
+// Strengths of row locks taken by locking reads
+const (
+	LockForUpdate      = "UPDATE"
+	LockForNoKeyUpdate = "NO KEY UPDATE"
+	LockForShare       = "SHARE"
+	LockForKeyShare    = "KEY SHARE"
+)
+
+// Policies of locking reads for rows locked by other transactions
+const (
+	LockWait       = ""
+	LockNoWait     = "NOWAIT"
+	LockSkipLocked = "SKIP LOCKED"
+)
+
+// PostgreSQL error code raised when a lock can not be acquired with NOWAIT or within lock timeout
+const lockNotAvailableCode = "55P03"
+
+/*
+Options of locking reads, like GetOneByIdLocked, that lock the selected rows until the end of the transaction.
+
+By default rows are locked FOR UPDATE and the read waits for rows locked by other transactions.
+SKIP LOCKED with a limit lets several workers claim different rows matching the same filter.
+*/
+type PostgresLockOptions struct {
+	// Lock strength: LockForUpdate (default), LockForNoKeyUpdate, LockForShare or LockForKeyShare
+	Strength string
+	// Policy for locked rows: LockWait (default), LockNoWait or LockSkipLocked
+	Wait string
+	// Maximum time in milliseconds to wait for a lock, 0 to use the server setting
+	Timeout int64
+}
+
+// Creates new lock options with FOR UPDATE strength.
+func NewPostgresLockOptions() *PostgresLockOptions {
+	return &PostgresLockOptions{
+		Strength: LockForUpdate,
+		Wait:     LockWait,
+	}
+}
+
+// Composes the locking clause like: FOR UPDATE SKIP LOCKED
+func (c *PostgresLockOptions) compose(correlationId string) (string, error) {
+	strength := c.Strength
+	switch strength {
+	case "":
+		strength = LockForUpdate
+	case LockForUpdate, LockForNoKeyUpdate, LockForShare, LockForKeyShare:
+	default:
+		return "", cerr.NewBadRequestError(correlationId, "INVALID_LOCK_STRENGTH",
+			"Lock strength "+c.Strength+" is not supported").
+			WithDetails("strength", c.Strength)
+	}
+
+	clause := " FOR " + strength
+	switch c.Wait {
+	case LockWait:
+	case LockNoWait, LockSkipLocked:
+		clause += " " + c.Wait
+	default:
+		return "", cerr.NewBadRequestError(correlationId, "INVALID_LOCK_WAIT",
+			"Lock wait policy "+c.Wait+" is not supported").
+			WithDetails("wait", c.Wait)
+	}
+
+	if c.Timeout < 0 {
+		return "", cerr.NewBadRequestError(correlationId, "INVALID_LOCK_TIMEOUT",
+			"Lock timeout can not be negative").
+			WithDetails("timeout", c.Timeout)
+	}
+	return clause, nil
+}
+
+// Executes a locking read in the transaction.
+// Lock timeout is set for the read only and the previous value is restored after it.
+//   - correlationId     (optional) transaction id to trace execution through call chain.
+//   - tx                a transaction to lock the rows in.
+//   - query             a query without locking clause.
+//   - options           (optional) lock options.
+//   - args              query arguments.
+// Returns retrieved items or error.
+func (c *PostgresPersistence) readLocked(correlationId string, tx pgx.Tx, query string,
+	options *PostgresLockOptions, args ...interface{}) ([]interface{}, error) {
+
+	if err := checkTransaction(correlationId, tx, c.QuotedTableName()); err != nil {
+		return nil, err
+	}
+	if options == nil {
+		options = NewPostgresLockOptions()
+	}
+	clause, err := options.compose(correlationId)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := transactionContext(tx)
+	var timeout string
+	if options.Timeout > 0 {
+		err = tx.QueryRow(ctx, "SELECT current_setting('lock_timeout')").Scan(&timeout)
+		if err == nil {
+			_, err = tx.Exec(ctx, "SELECT set_config('lock_timeout', $1, true)",
+				strconv.FormatInt(options.Timeout, 10)+"ms")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	qResult, err := tx.Query(ctx, query+clause, args...)
+	if err != nil {
+		return nil, c.convertLockError(correlationId, err)
+	}
+	items, err := c.readItems(qResult)
+	if err != nil {
+		return nil, c.convertLockError(correlationId, err)
+	}
+
+	if options.Timeout > 0 {
+		if _, err = tx.Exec(ctx, "SELECT set_config('lock_timeout', $1, true)", timeout); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// Converts error raised when rows are locked by another transaction into ConflictError
+func (c *PostgresPersistence) convertLockError(correlationId string, err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == lockNotAvailableCode {
+		return cerr.NewConflictError(correlationId, "LOCK_NOT_AVAILABLE",
+			"Rows in "+c.QuotedTableName()+" are locked by another transaction").
+			WithCause(err)
+	}
+	return err
+}
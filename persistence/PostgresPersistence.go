@@ -11,6 +11,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	cconf "github.com/pip-services3-go/pip-services3-commons-go/config"
@@ -54,7 +55,15 @@ accessing c._db or c._collection properties.
 - options:
    - connect_timeout:      (optional) number of milliseconds to wait before timing out when connecting a new client (default: 0)
    - idle_timeout:         (optional) number of milliseconds a client must sit idle in the pool and not be checked out (default: 10000)
-   - max_pool_size:        (optional) maximum number of clients the pool should contain (default: 10)
+   - max_pool_size:        (optional) maximum number of clients the pool should contain (default: 2)
+   - min_pool_size:        (optional) minimum number of clients the pool keeps open (default: 0)
+   - max_conn_lifetime:    (optional) number of milliseconds after which a client is closed and replaced (default: 3600000)
+   - health_check_period:  (optional) number of milliseconds between health checks of idle clients (default: 60000)
+   - lazy_connect:         (optional) true to postpone connecting to the server until the first client is requested (default: false)
+   - drain_timeout:        (optional) number of milliseconds to wait for running queries on close before they are canceled (default: 10000)
+   - schema_drift:         (optional) action when existing table differs from declared columns and indexes:
+                           "none", "warn", "fail" to fail open or "apply" to add missing columns and indexes (default: "none")
+   - json_conversion:      (optional) true to convert rows and objects through JSON instead of mapping columns to struct fields (default: false)
 
 ### References ###
 
@@ -111,6 +120,16 @@ type PostgresPersistence struct {
 	opened           bool
 	localConnection  bool
 	schemaStatements []string
+	schemaIndexes    []postgresIndex
+	schemaColumns    map[string]string
+	schemaDrift      string
+	jsonConversion   bool
+	// JSON columns that are not described by the prototype
+	jsonColumns map[string]bool
+	// Serializes Open and Close calls
+	openLock sync.Mutex
+	// Protects opened, Client and schema definitions
+	lock sync.RWMutex
 
 	//The dependency resolver.
 	DependencyResolver *cref.DependencyResolver
@@ -118,7 +137,7 @@ type PostgresPersistence struct {
 	Logger *clog.CompositeLogger
 	//The PostgreSQL connection component.
 	Connection *conn.PostgresConnection
-	//The PostgreSQL connection pool object.
+	//The PostgreSQL connection pool object set on open. Use GetClient to get the current pool.
 	Client *pgxpool.Pool
 	//The PostgreSQL database name.
 	DatabaseName string
@@ -170,6 +189,8 @@ func (c *PostgresPersistence) Configure(config *cconf.ConfigParams) {
 	c.TableName = config.GetAsStringWithDefault("table", c.TableName)
 	c.MaxPageSize = config.GetAsIntegerWithDefault("options.max_page_size", c.MaxPageSize)
 	c.SchemaName = config.GetAsStringWithDefault("schema", c.SchemaName)
+	c.schemaDrift = config.GetAsStringWithDefault("options.schema_drift", c.schemaDrift)
+	c.jsonConversion = config.GetAsBooleanWithDefault("options.json_conversion", c.jsonConversion)
 }
 
 // Sets references to dependent components.
@@ -244,6 +265,75 @@ func (c *PostgresPersistence) EnsureIndex(name string, keys map[string]string, o
 	builder += "(" + fields + ")"
 
 	c.EnsureSchema(builder)
+
+	index := postgresIndex{
+		name:      name,
+		keys:      make([]string, 0, len(keys)),
+		unique:    options["unique"] != "",
+		statement: builder,
+	}
+	for key := range keys {
+		index.keys = append(index.keys, key)
+	}
+	c.lock.Lock()
+	c.schemaIndexes = append(c.schemaIndexes, index)
+	c.lock.Unlock()
+}
+
+// Adds statements to create a table with columns derived from the prototype struct.
+// Column names are taken from json tags and types from field types:
+// string - TEXT, int64 - BIGINT, time.Time - TIMESTAMPTZ, maps, slices and structs - JSONB.
+// Types, NOT NULL constraints, defaults, uniqueness and indexes can be set in postgres tags:
+//
+//     Key string `json:"key" postgres:"type:VARCHAR(50);notnull;unique"`
+//
+// The "id" column is a primary key unless another column is tagged as primarykey.
+// Several columns tagged as primarykey form a composite primary key.
+func (c *PostgresPersistence) EnsureTableFromPrototype() {
+	columns := getPostgresColumns(c.Prototype)
+	if len(columns) == 0 {
+		c.Logger.Warn("PostgresPersistence", "Prototype of %s is not a struct, table is not created", c.QuotedTableName())
+		return
+	}
+
+	keys := make([]string, 0, 1)
+	for _, column := range columns {
+		if column.primaryKey {
+			keys = append(keys, c.QuoteIdentifier(column.name))
+		}
+	}
+
+	definitions := make([]string, 0, len(columns)+1)
+	schemaColumns := make(map[string]string, len(columns))
+	for _, column := range columns {
+		// Composite primary key is declared as a table constraint
+		if column.primaryKey && len(keys) > 1 {
+			keyColumn := *column
+			keyColumn.primaryKey = false
+			keyColumn.notNull = true
+			column = &keyColumn
+		}
+		definitions = append(definitions, column.definition(c.QuoteIdentifier))
+		schemaColumns[column.name] = column.sqlType
+		if column.defaultValue != "" {
+			schemaColumns[column.name] += " DEFAULT " + column.defaultValue
+		}
+	}
+	if len(keys) > 1 {
+		definitions = append(definitions, "PRIMARY KEY ("+strings.Join(keys, ", ")+")")
+	}
+	c.EnsureSchema("CREATE TABLE IF NOT EXISTS " + c.QuotedTableName() + " (" + strings.Join(definitions, ", ") + ")")
+
+	for _, column := range columns {
+		if column.index {
+			c.EnsureIndex(c.TableName+"_"+column.name, map[string]string{c.QuoteIdentifier(column.name): "1"}, nil)
+		}
+	}
+
+	// Columns to verify the existing table
+	c.lock.Lock()
+	c.schemaColumns = schemaColumns
+	c.lock.Unlock()
 }
 
 // Defines a database schema for this persistence, have to call in child class
@@ -265,18 +355,37 @@ func (c *PostgresPersistence) DefineSchema() {
 // Adds a statement to schema definition
 //   - schemaStatement a statement to be added to the schema
 func (c *PostgresPersistence) EnsureSchema(schemaStatement string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 	c.schemaStatements = append(c.schemaStatements, schemaStatement)
 }
 
 // Clears all auto-created objects
 func (c *PostgresPersistence) ClearSchema() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 	c.schemaStatements = []string{}
+	c.schemaIndexes = nil
+	c.schemaColumns = nil
 }
 
 // Converts object value from internal to func (c * PostgresPersistence) format.
+// Columns are assigned to struct fields of the prototype with matching json tags,
+// and rows are converted through JSON when the prototype is not a struct or json_conversion is set.
 //   - value     an object in internal format to convert.
 // Returns converted object in func (c * PostgresPersistence) format.
 func (c *PostgresPersistence) ConvertToPublic(rows pgx.Rows) interface{} {
+	if !c.jsonConversion {
+		item, ok, err := c.convertRowToObject(rows)
+		if ok && err == nil {
+			return item
+		}
+		if err != nil {
+			c.Logger.Warn("PostgresPersistence", "Failed to convert row of %s to %s, converting through JSON: %s",
+				c.QuotedTableName(), c.Prototype, err.Error())
+		}
+	}
+
 	values, valErr := rows.Values()
 	if valErr != nil || values == nil {
 		return nil
@@ -330,6 +439,8 @@ func (c *PostgresPersistence) QuotedTableName() string {
 // Checks if the component is opened.
 // Returns true if the component has been opened and false otherwise.
 func (c *PostgresPersistence) IsOpen() bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
 	return c.opened
 }
 
@@ -337,7 +448,10 @@ func (c *PostgresPersistence) IsOpen() bool {
 //   - correlationId 	(optional) transaction id to trace execution through call chain.
 //   - Returns 			 error or nil no errors occured.
 func (c *PostgresPersistence) Open(correlationId string) (err error) {
-	if c.opened {
+	c.openLock.Lock()
+	defer c.openLock.Unlock()
+
+	if c.IsOpen() {
 		return nil
 	}
 
@@ -346,24 +460,21 @@ func (c *PostgresPersistence) Open(correlationId string) (err error) {
 		c.localConnection = true
 	}
 
-	if c.localConnection {
-		err = c.Connection.Open(correlationId)
-	}
-
-	if err == nil && c.Connection == nil {
-		err = cerr.NewInvalidStateError(correlationId, "NO_CONNECTION", "PostgreSQL connection is missing")
-	}
+	// Shared connections count their users, so it is opened by the first user
+	// and gets closed when the last user closes it
+	err = c.Connection.Open(correlationId)
 
 	if err == nil && !c.Connection.IsOpen() {
 		err = cerr.NewConnectionError(correlationId, "CONNECT_FAILED", "PostgreSQL connection is not opened")
 	}
 
-	c.opened = false
-
 	if err != nil {
 		return err
 	}
+
+	c.lock.Lock()
 	c.Client = c.Connection.GetConnection()
+	c.lock.Unlock()
 	c.DatabaseName = c.Connection.GetDatabaseName()
 
 	// Define database schema
@@ -372,38 +483,56 @@ func (c *PostgresPersistence) Open(correlationId string) (err error) {
 	// Recreate objects
 	err = c.CreateSchema(correlationId)
 	if err != nil {
+		c.lock.Lock()
 		c.Client = nil
-		err = cerr.NewConnectionError(correlationId, "CONNECT_FAILED", "Connection to postgres failed").WithCause(err)
-	} else {
-		c.opened = true
-		c.Logger.Debug(correlationId, "Connected to postgres database %s, collection %s", c.DatabaseName, c.QuotedTableName())
+		c.lock.Unlock()
+		c.Connection.Close(correlationId)
+		if _, ok := err.(*cerr.ApplicationError); !ok {
+			err = cerr.NewConnectionError(correlationId, "CONNECT_FAILED", "Connection to postgres failed").WithCause(err)
+		}
+		return err
 	}
 
-	return err
-
+	c.lock.Lock()
+	c.opened = true
+	c.lock.Unlock()
+	c.Logger.Debug(correlationId, "Connected to postgres database %s, collection %s", c.DatabaseName, c.QuotedTableName())
+	return nil
 }
 
 // Closes component and frees used resources.
 //   - correlationId 	(optional) transaction id to trace execution through call chain.
 //   - Returns 			error or nil no errors occured.
 func (c *PostgresPersistence) Close(correlationId string) (err error) {
-	if !c.opened {
-		return nil
+	_, err = c.CloseWithResult(correlationId)
+	return err
+}
+
+// Closes component like Close and reports operations aborted on close.
+// Operations are aborted only when the connection is closed by its last user.
+//   - correlationId 	(optional) transaction id to trace execution through call chain.
+//   - Returns 			the number of canceled operations and error or nil no errors occured.
+func (c *PostgresPersistence) CloseWithResult(correlationId string) (aborted int, err error) {
+	c.openLock.Lock()
+	defer c.openLock.Unlock()
+
+	if !c.IsOpen() {
+		return 0, nil
 	}
 
 	if c.Connection == nil {
-		return cerr.NewInvalidStateError(correlationId, "NO_CONNECTION", "Postgres connection is missing")
+		return 0, cerr.NewInvalidStateError(correlationId, "NO_CONNECTION", "Postgres connection is missing")
 	}
 
-	if c.localConnection {
-		err = c.Connection.Close(correlationId)
-	}
+	aborted, err = c.Connection.CloseWithResult(correlationId)
 	if err != nil {
-		return err
+		return 0, err
 	}
+	c.lock.Lock()
 	c.opened = false
 	c.Client = nil
-	return nil
+	c.lock.Unlock()
+	return aborted, nil
 }
 
 // Clears component state.
@@ -417,55 +546,253 @@ func (c *PostgresPersistence) Clear(correlationId string) error {
 
 	query := "DELETE FROM " + c.QuotedTableName()
 
-	qResult, err := c.Client.Query(context.TODO(), query)
+	qResult, err := c.Query(context.TODO(), correlationId, query)
 	if err != nil {
-		err = cerr.NewConnectionError(correlationId, "CONNECT_FAILED", "Connection to postgres failed").
-			WithCause(err)
+		if _, ok := err.(*cerr.ApplicationError); !ok {
+			err = cerr.NewConnectionError(correlationId, "CONNECT_FAILED", "Connection to postgres failed").
+				WithCause(err)
+		}
+		return err
 	}
 	defer qResult.Close()
-	return err
+	return nil
 }
 
+// Creates database objects defined by schema statements when the table does not exist.
+// Concurrent creation by several processes is serialized with an advisory lock
+// keyed by the schema and table name, so objects are created only once.
+//   - correlationId 	(optional) transaction id to trace execution through call chain.
+//   - Returns 			error or nil if objects were created or already exist.
 func (c *PostgresPersistence) CreateSchema(correlationId string) (err error) {
-	if c.schemaStatements == nil || len(c.schemaStatements) == 0 {
+	c.lock.RLock()
+	statements := append([]string{}, c.schemaStatements...)
+	client := c.Client
+	c.lock.RUnlock()
+
+	if len(statements) == 0 {
 		return nil
 	}
-
-	// Check if table exist to determine weither to auto create objects
-	query := "SELECT to_regclass('" + c.QuotedTableName() + "')"
-	qResult, qErr := c.Client.Query(context.TODO(), query)
-	if qErr != nil {
-		return qErr
+	if client == nil {
+		return cerr.NewInvalidStateError(correlationId, "NOT_OPENED", "PostgreSQL connection is not opened")
 	}
-	defer qResult.Close()
-	// If table already exists then exit
-	if qResult != nil && qResult.Next() {
-		val, cErr := qResult.Values()
-		if cErr != nil {
-			return cErr
+
+	ctx := context.Background()
+	if c.Connection != nil {
+		opCtx, done, opErr := c.Connection.BeginOperation(ctx, correlationId)
+		if opErr != nil {
+			return opErr
 		}
+		defer done()
+		ctx = opCtx
+	}
 
-		if len(val) > 0 && val[0] == c.TableName {
-			return nil
+	// Advisory locks are held by a session, so all statements use the same connection
+	conn, err := client.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	lockArgs := []interface{}{c.SchemaName, c.TableName}
+	lockKey := "hashtext(COALESCE(NULLIF($1::text, ''), current_schema())), hashtext($2::text)"
+	_, err = conn.Exec(ctx, "SELECT pg_advisory_lock("+lockKey+")", lockArgs...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, unlockErr := conn.Exec(context.Background(), "SELECT pg_advisory_unlock("+lockKey+")", lockArgs...)
+		if unlockErr != nil {
+			// Do not return a connection that may still hold the lock into the pool
+			conn.Conn().Close(context.Background())
 		}
+	}()
+
+	// Check if table exist to determine weither to auto create objects.
+	// It is checked after the lock is acquired, since another process could just create it
+	exists := false
+	err = conn.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", c.QuotedTableName()).Scan(&exists)
+	if err != nil {
+		return err
 	}
+	// If table already exists then verify it and exit
+	if exists {
+		return c.checkSchemaDrift(ctx, correlationId, conn)
+	}
+
 	c.Logger.Debug(correlationId, "Table "+c.QuotedTableName()+" does not exist. Creating database objects...")
-	wg := sync.WaitGroup{}
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		for _, dml := range c.schemaStatements {
-			qResult, err := c.Client.Query(context.TODO(), dml)
-			if err != nil {
-				c.Logger.Error(correlationId, err, "Failed to autocreate database object")
-			}
-			if qResult != nil {
-				qResult.Close()
-			}
+	for _, dml := range statements {
+		_, err = conn.Exec(ctx, dml)
+		if err != nil {
+			c.Logger.Error(correlationId, err, "Failed to autocreate database object")
+			return cerr.NewInternalError(correlationId, "CREATE_SCHEMA_FAILED",
+				"Failed to create database objects for "+c.QuotedTableName()).
+				WithDetails("statement", dml).WithCause(err)
+		}
+	}
+	return nil
+}
+
+// Executes a read-only query. If the connection has replicas the query is sent to one of them,
+// when the replica does not respond the query is repeated on the primary.
+//   - ctx               operation context.
+//   - correlationId     (optional) transaction id to trace execution through call chain.
+//   - query             a query to execute.
+//   - args              query arguments.
+// Returns query result rows or error.
+func (c *PostgresPersistence) ReadQuery(ctx context.Context, correlationId string, query string, args ...interface{}) (pgx.Rows, error) {
+	client, err := c.getClient(correlationId)
+	if err != nil {
+		return nil, err
+	}
+	if c.Connection == nil || c.Connection.GetConnection() != client {
+		return c.query(ctx, correlationId, client, query, args...)
+	}
+
+	pool := c.Connection.GetReadConnection()
+	if pool == nil || pool == client {
+		return c.query(ctx, correlationId, client, query, args...)
+	}
+
+	rows, err := c.query(ctx, correlationId, pool, query, args...)
+	if _, ok := err.(*cerr.ApplicationError); ok {
+		return nil, err
+	}
+	var pgErr *pgconn.PgError
+	if err != nil && !errors.As(err, &pgErr) && ctx.Err() == nil {
+		c.Connection.MarkReplicaFailed(correlationId, pool)
+		return c.query(ctx, correlationId, client, query, args...)
+	}
+	return rows, err
+}
+
+// Executes a query on the primary server.
+//   - ctx               operation context.
+//   - correlationId     (optional) transaction id to trace execution through call chain.
+//   - query             a query to execute.
+//   - args              query arguments.
+// Returns query result rows or error if the query failed or the connection is closed.
+func (c *PostgresPersistence) Query(ctx context.Context, correlationId string, query string, args ...interface{}) (pgx.Rows, error) {
+	client, err := c.getClient(correlationId)
+	if err != nil {
+		return nil, err
+	}
+	return c.query(ctx, correlationId, client, query, args...)
+}
+
+// Executes a query on the pool. The query is registered as a running operation
+// in the connection, so closing of the connection waits for it to complete.
+func (c *PostgresPersistence) query(ctx context.Context, correlationId string, pool *pgxpool.Pool,
+	query string, args ...interface{}) (pgx.Rows, error) {
+	if c.Connection == nil {
+		return pool.Query(ctx, query, args...)
+	}
+
+	ctx, done, err := c.Connection.BeginOperation(ctx, correlationId)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		done()
+		return nil, err
+	}
+	return &operationRows{Rows: rows, done: done}, nil
+}
+
+// Begins a transaction on the primary server. The transaction must be committed or rolled back.
+//   - correlationId     (optional) transaction id to trace execution through call chain.
+// Returns started transaction or error.
+func (c *PostgresPersistence) BeginTransaction(correlationId string) (*PostgresTransaction, error) {
+	client, err := c.getClient(correlationId)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.TODO()
+	done := func() {}
+	if c.Connection != nil {
+		ctx, done, err = c.Connection.BeginOperation(ctx, correlationId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := client.Begin(ctx)
+	if err != nil {
+		done()
+		return nil, err
+	}
+	return &PostgresTransaction{Tx: tx, ctx: ctx, done: done}, nil
+}
+
+// Executes a function in a transaction. The transaction is committed when the function
+// succeeds and rolled back when it returns error or panics.
+//   - correlationId     (optional) transaction id to trace execution through call chain.
+//   - action            a function to execute in the transaction.
+// Returns error of the function, or error if the transaction failed.
+func (c *PostgresPersistence) WithTransaction(correlationId string, action func(tx *PostgresTransaction) error) (err error) {
+	tx, err := c.BeginTransaction(correlationId)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback(context.TODO())
+			panic(r)
 		}
 	}()
-	wg.Wait()
-	return qResult.Err()
+
+	if err = action(tx); err != nil {
+		tx.Rollback(context.TODO())
+		return err
+	}
+	return tx.Commit(tx.Context())
+}
+
+// Query result rows that complete the connection operation when they are read or closed
+type operationRows struct {
+	pgx.Rows
+	done func()
+}
+
+func (r *operationRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.done()
+	return false
+}
+
+func (r *operationRows) Close() {
+	r.Rows.Close()
+	r.done()
+}
+
+// Gets the current connection pool. When the persistence is opened it is taken
+// from the connection component, so the persistence follows pool changes after failover.
+// Returns the connection pool or nil if the persistence is not opened or the connection is closed.
+func (c *PostgresPersistence) GetClient() *pgxpool.Pool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if c.opened && c.Connection != nil {
+		return c.Connection.GetConnection()
+	}
+	return c.Client
+}
+
+// Gets the current connection pool or error if it can not be used
+func (c *PostgresPersistence) getClient(correlationId string) (*pgxpool.Pool, error) {
+	client := c.GetClient()
+	if client != nil {
+		return client, nil
+	}
+	if c.IsOpen() {
+		return nil, cerr.NewInvalidStateError(correlationId, "CONNECTION_CLOSED",
+			"PostgreSQL connection used by "+c.QuotedTableName()+" is closed")
+	}
+	return nil, cerr.NewInvalidStateError(correlationId, "NOT_OPENED",
+		"PostgreSQL persistence "+c.QuotedTableName()+" is not opened")
 }
 
 // Generates a list of column names to use in SQL statements like: "column1,column2,column3"
@@ -527,6 +854,11 @@ func (c *PostgresPersistence) GenerateParameters(values interface{}) string {
 //   - values a key-value map with columns and values
 // Returns a generated list of column sets
 func (c *PostgresPersistence) GenerateSetParameters(values interface{}) (setParams string, columns string) {
+	return c.generateSetParameters(values, 0)
+}
+
+// Generates a list of column sets numbered after the given number of preceding parameters.
+func (c *PostgresPersistence) generateSetParameters(values interface{}, offset int) (setParams string, columns string) {
 
 	items := c.convertToMap(values)
 	if items == nil {
@@ -534,7 +866,7 @@ func (c *PostgresPersistence) GenerateSetParameters(values interface{}) (setPara
 	}
 	setParamsBuf := strings.Builder{}
 	colBuf := strings.Builder{}
-	index := 1
+	index := offset + 1
 	for column := range items {
 		if setParamsBuf.String() != "" {
 			setParamsBuf.WriteString(",")
@@ -569,7 +901,15 @@ func (c *PostgresPersistence) GenerateValues(columns string, values interface{})
 	return results
 }
 
+// Converts a data object into a map of column values. Fields are mapped to columns
+// by json tags and keep their Go types, unless json_conversion option is set.
 func (c *PostgresPersistence) convertToMap(values interface{}) map[string]interface{} {
+	if !c.jsonConversion {
+		if items, ok := convertObjectToMap(values); ok {
+			return items
+		}
+	}
+
 	mRes, mErr := json.Marshal(values)
 	if mErr != nil {
 		c.Logger.Error("PostgresPersistence", mErr, "Error data convertion")
@@ -628,7 +968,7 @@ func (c *PostgresPersistence) GetPageByFilter(correlationId string, filter inter
 	}
 
 	query += " LIMIT " + strconv.FormatInt(take, 10)
-	qResult, qErr := c.Client.Query(context.TODO(), query)
+	qResult, qErr := c.ReadQuery(context.TODO(), correlationId, query)
 
 	if qErr != nil {
 		return nil, qErr
@@ -654,7 +994,7 @@ func (c *PostgresPersistence) GetPageByFilter(correlationId string, filter inter
 			}
 		}
 
-		qResult2, qErr2 := c.Client.Query(context.TODO(), query)
+		qResult2, qErr2 := c.ReadQuery(context.TODO(), correlationId, query)
 		if qErr2 != nil {
 			return nil, qErr2
 		}
@@ -690,7 +1030,7 @@ func (c *PostgresPersistence) GetCountByFilter(correlationId string, filter inte
 		}
 	}
 
-	qResult, qErr := c.Client.Query(context.TODO(), query)
+	qResult, qErr := c.ReadQuery(context.TODO(), correlationId, query)
 	if qErr != nil {
 		return 0, qErr
 	}
@@ -709,6 +1049,66 @@ func (c *PostgresPersistence) GetCountByFilter(correlationId string, filter inte
 	return count, qResult.Err()
 }
 
+// Gets aggregated values of data items retrieved by a given filter and grouped by the aggregation groups.
+// The filter and the having condition of the aggregation refer to the same arguments,
+// and sorting can use aliases of aggregation fields, like: total DESC
+//   - correlationId     (optional) transaction id to trace execution through call chain.
+//   - filter            (optional) a filter condition with $1, $2... parameters.
+//   - aggregation       groups and aggregates to compute.
+//   - sort              (optional) sorting expression.
+//   - proto             (optional) a struct type with json tags matching aliases to return rows as,
+//                       rows are returned as map[string]interface{} when it is nil.
+//   - args              filter and having arguments.
+// Returns aggregated rows or error.
+func (c *PostgresPersistence) Aggregate(correlationId string, filter string, aggregation *PostgresAggregation,
+	sort string, proto reflect.Type, args ...interface{}) (items []interface{}, err error) {
+
+	if aggregation == nil {
+		aggregation = NewPostgresAggregation()
+	}
+	query, values, err := aggregation.compile(correlationId, c.QuotedTableName(), c.QuoteIdentifier, filter, sort, len(args))
+	if err != nil {
+		return nil, err
+	}
+	if proto != nil && getPostgresMapping(proto) == nil {
+		return nil, cerr.NewBadRequestError(correlationId, "INVALID_PROTOTYPE",
+			"Aggregated rows can only be returned as structs").
+			WithDetails("type", proto.String())
+	}
+
+	qResult, qErr := c.ReadQuery(context.TODO(), correlationId, query, append(append([]interface{}{}, args...), values...)...)
+	if qErr != nil {
+		return nil, qErr
+	}
+	defer qResult.Close()
+
+	items = make([]interface{}, 0)
+	for qResult.Next() {
+		var item interface{}
+		if proto != nil {
+			var ok bool
+			item, ok, err = convertRowToStruct(qResult, proto)
+			if !ok || err != nil {
+				convErr := cerr.NewInternalError(correlationId, "CONVERSION_FAILED",
+					"Failed to convert aggregated row of "+c.QuotedTableName()+" to "+proto.String())
+				if err != nil {
+					convErr = convErr.WithCause(err)
+				}
+				return nil, convErr
+			}
+		} else if item, err = convertRowToMap(qResult); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if qResult.Err() != nil {
+		return nil, qResult.Err()
+	}
+
+	c.Logger.Trace(correlationId, "Aggregated %d rows from %s", len(items), c.TableName)
+	return items, nil
+}
+
 // Gets a list of data items retrieved by a given filter and sorted according to sort parameters.
 // This method shall be called by a func (c * PostgresPersistence) getListByFilter method from child class that
 // receives FilterParams and converts them into a filter function.
@@ -739,7 +1139,7 @@ func (c *PostgresPersistence) GetListByFilter(correlationId string, filter inter
 		}
 	}
 
-	qResult, qErr := c.Client.Query(context.TODO(), query)
+	qResult, qErr := c.ReadQuery(context.TODO(), correlationId, query)
 
 	if qErr != nil {
 		return nil, qErr
@@ -773,7 +1173,7 @@ func (c *PostgresPersistence) GetOneRandom(correlationId string, filter interfac
 		}
 	}
 
-	qResult, qErr := c.Client.Query(context.TODO(), query)
+	qResult, qErr := c.ReadQuery(context.TODO(), correlationId, query)
 	if qErr != nil {
 		return nil, qErr
 	}
@@ -807,7 +1207,7 @@ func (c *PostgresPersistence) GetOneRandom(correlationId string, filter interfac
 	rand.Seed(time.Now().UnixNano())
 	pos := rand.Int63n(int64(count))
 	query += " OFFSET " + strconv.FormatInt(pos, 10) + " LIMIT 1"
-	qResult2, qErr2 := c.Client.Query(context.TODO(), query)
+	qResult2, qErr2 := c.ReadQuery(context.TODO(), correlationId, query)
 	if qErr2 != nil {
 		return nil, qErr
 	}
@@ -832,12 +1232,20 @@ func (c *PostgresPersistence) Create(correlationId string, item interface{}) (re
 		return nil, nil
 	}
 
-	row := c.Overrides.ConvertFromPublic(item)
+	return c.create(correlationId, c.Overrides.ConvertFromPublic(item))
+}
+
+// Inserts a row in internal format and converts the stored row back to public format.
+func (c *PostgresPersistence) create(correlationId string, row interface{}) (result interface{}, err error) {
 	columns := c.GenerateColumns(row)
-	params := c.GenerateParameters(row)
 	values := c.GenerateValues(columns, row)
-	query := "INSERT INTO " + c.QuotedTableName() + " (" + columns + ") VALUES (" + params + ") RETURNING *"
-	qResult, qErr := c.Client.Query(context.TODO(), query, values...)
+	return c.insert(correlationId, columns, "VALUES ("+c.GenerateParameters(row)+")", values)
+}
+
+// Inserts a row from the source, like: VALUES ($1,$2), and converts the stored row back to public format.
+func (c *PostgresPersistence) insert(correlationId string, columns string, source string, values []interface{}) (result interface{}, err error) {
+	query := "INSERT INTO " + c.QuotedTableName() + " (" + columns + ") " + source + " RETURNING *"
+	qResult, qErr := c.Query(context.TODO(), correlationId, query, values...)
 	if qErr != nil {
 		return nil, qErr
 	}
@@ -845,10 +1253,10 @@ func (c *PostgresPersistence) Create(correlationId string, item interface{}) (re
 	if !qResult.Next() {
 		return nil, qResult.Err()
 	}
-	item = c.Overrides.ConvertToPublic(qResult)
-	id := cmpersist.GetObjectId(item)
+	result = c.Overrides.ConvertToPublic(qResult)
+	id := cmpersist.GetObjectId(result)
 	c.Logger.Trace(correlationId, "Created in %s with id = %s", c.TableName, id)
-	return item, nil
+	return result, nil
 
 }
 
@@ -856,32 +1264,183 @@ func (c *PostgresPersistence) Create(correlationId string, item interface{}) (re
 // This method shall be called by a func (c * PostgresPersistence) deleteByFilter method from child class that
 // receives FilterParams and converts them into a filter function.
 //   - correlationId     (optional) transaction id to trace execution through call chain.
-//   - filter            (optional) a filter JSON object.
-//   - Returns           error or nil for success.
-func (c *PostgresPersistence) DeleteByFilter(correlationId string, filter string) (err error) {
+//   - filter            (optional) a filter condition with $1, $2... parameters.
+//   - args              (optional) values of filter parameters.
+//   - Returns           number of deleted items or error.
+func (c *PostgresPersistence) DeleteByFilter(correlationId string, filter string, args ...interface{}) (count int64, err error) {
+	qResult, qErr := c.Query(context.TODO(), correlationId, c.composeDeleteByFilter(filter, false), args...)
+	if qErr != nil {
+		return 0, qErr
+	}
+	count, err = c.readAffectedCount(qResult)
+	if err != nil {
+		return 0, err
+	}
+	c.Logger.Trace(correlationId, "Deleted %d items from %s", count, c.TableName)
+	return count, nil
+}
+
+// Deletes data items that match to a given filter and returns them.
+//   - correlationId     (optional) transaction id to trace execution through call chain.
+//   - filter            (optional) a filter condition with $1, $2... parameters.
+//   - args              (optional) values of filter parameters.
+//   - Returns           deleted items or error.
+func (c *PostgresPersistence) DeleteByFilterReturning(correlationId string, filter string, args ...interface{}) (items []interface{}, err error) {
+	qResult, qErr := c.Query(context.TODO(), correlationId, c.composeDeleteByFilter(filter, true), args...)
+	if qErr != nil {
+		return nil, qErr
+	}
+	items, err = c.readItems(qResult)
+	if err != nil {
+		return nil, err
+	}
+	c.Logger.Trace(correlationId, "Deleted %d items from %s", len(items), c.TableName)
+	return items, nil
+}
+
+func (c *PostgresPersistence) composeDeleteByFilter(filter string, returning bool) string {
 	query := "DELETE FROM " + c.QuotedTableName()
 	if filter != "" {
 		query += " WHERE " + filter
 	}
+	if returning {
+		query += " RETURNING *"
+	}
+	return query
+}
 
-	qResult, qErr := c.Client.Query(context.TODO(), query)
-	defer qResult.Close()
+// Updates fields of data items that match to a given filter.
+// This method shall be called by a func (c * PostgresPersistence) updateByFilter method from child class that
+// receives FilterParams and converts them into a filter function.
+//   - correlationId     (optional) transaction id to trace execution through call chain.
+//   - filter            (optional) a filter condition with $1, $2... parameters.
+//   - data              a map with fields to be updated.
+//   - args              (optional) values of filter parameters.
+//   - Returns           number of updated items or error.
+func (c *PostgresPersistence) UpdateByFilter(correlationId string, filter string, data *cdata.AnyValueMap, args ...interface{}) (count int64, err error) {
+	if data == nil {
+		return 0, nil
+	}
+	setParams, values := c.composePartialSet(data, args)
+	return c.updateByFilter(correlationId, filter, setParams, values)
+}
 
+// Updates fields of data items that match to a given filter and returns updated items.
+//   - correlationId     (optional) transaction id to trace execution through call chain.
+//   - filter            (optional) a filter condition with $1, $2... parameters.
+//   - data              a map with fields to be updated.
+//   - args              (optional) values of filter parameters.
+//   - Returns           updated items or error.
+func (c *PostgresPersistence) UpdateByFilterReturning(correlationId string, filter string, data *cdata.AnyValueMap, args ...interface{}) (items []interface{}, err error) {
+	if data == nil {
+		return nil, nil
+	}
+	setParams, values := c.composePartialSet(data, args)
+	return c.updateByFilterReturning(correlationId, filter, setParams, values)
+}
+
+// Composes SET clause with parameters numbered after filter parameters.
+// Returns the clause and filter values followed by set values.
+func (c *PostgresPersistence) composePartialSet(data *cdata.AnyValueMap, args []interface{}) (string, []interface{}) {
+	row := c.Overrides.ConvertFromPublicPartial(data.Value())
+	setParams, columns := c.generateSetParameters(row, len(args))
+	values := append(append([]interface{}{}, args...), c.GenerateValues(columns, row)...)
+	return setParams, values
+}
+
+func (c *PostgresPersistence) composeUpdateByFilter(filter string, setParams string, returning bool) string {
+	query := "UPDATE " + c.QuotedTableName() + " SET " + setParams
+	if filter != "" {
+		query += " WHERE " + filter
+	}
+	if returning {
+		query += " RETURNING *"
+	}
+	return query
+}
+
+func (c *PostgresPersistence) updateByFilter(correlationId string, filter string, setParams string, values []interface{}) (count int64, err error) {
+	qResult, qErr := c.Query(context.TODO(), correlationId, c.composeUpdateByFilter(filter, setParams, false), values...)
 	if qErr != nil {
-		return qErr
+		return 0, qErr
 	}
-	defer qResult.Close()
+	count, err = c.readAffectedCount(qResult)
+	if err != nil {
+		return 0, err
+	}
+	c.Logger.Trace(correlationId, "Updated %d items in %s", count, c.TableName)
+	return count, nil
+}
 
-	var count int64 = 0
+func (c *PostgresPersistence) updateByFilterReturning(correlationId string, filter string, setParams string, values []interface{}) (items []interface{}, err error) {
+	qResult, qErr := c.Query(context.TODO(), correlationId, c.composeUpdateByFilter(filter, setParams, true), values...)
+	if qErr != nil {
+		return nil, qErr
+	}
+	items, err = c.readItems(qResult)
+	if err != nil {
+		return nil, err
+	}
+	c.Logger.Trace(correlationId, "Updated %d items in %s", len(items), c.TableName)
+	return items, nil
+}
+
+// Applies atomic update operations to the single row that matches the condition.
+//   - condition     a condition with $1, $2... parameters.
+//   - args          values of condition parameters.
+// Returns the updated item, nil if no row matches, or error.
+func (c *PostgresPersistence) updateByOperations(correlationId string, condition string, args []interface{},
+	operations *PostgresUpdateOperations) (result interface{}, err error) {
+
+	setParams, values, err := operations.compile(correlationId, c.QuoteIdentifier, c.isJsonColumn, len(args))
+	if err != nil {
+		return nil, err
+	}
+	values = append(append([]interface{}{}, args...), values...)
+	query := c.composeUpdateByFilter(condition, setParams, true)
+
+	qResult, qErr := c.Query(context.TODO(), correlationId, query, values...)
+	if qErr != nil {
+		return nil, qErr
+	}
+	defer qResult.Close()
 	if !qResult.Next() {
-		return qResult.Err()
+		return nil, qResult.Err()
 	}
-	rows, _ := qResult.Values()
-	if len(rows) == 1 {
-		count = cconv.LongConverter.ToLong(rows[0])
+	return c.Overrides.ConvertToPublic(qResult), nil
+}
+
+// Checks if the column is mapped to JSON or JSONB type in the prototype
+// or is a known JSON column like the data column of IdentifiableJsonPostgresPersistence.
+func (c *PostgresPersistence) isJsonColumn(name string) bool {
+	if c.jsonColumns[name] {
+		return true
 	}
-	c.Logger.Trace(correlationId, "Deleted %d items from %s", count, c.TableName)
-	return nil
+	mapping := getPostgresMapping(c.Prototype)
+	if mapping == nil {
+		return false
+	}
+	column, ok := mapping.byName[name]
+	return ok && strings.HasPrefix(strings.ToUpper(column.sqlType), "JSON")
+}
+
+// Reads the number of rows affected by a statement that returns no rows.
+func (c *PostgresPersistence) readAffectedCount(qResult pgx.Rows) (int64, error) {
+	qResult.Close()
+	if err := qResult.Err(); err != nil {
+		return 0, err
+	}
+	return qResult.CommandTag().RowsAffected(), nil
+}
+
+// Reads all returned rows converted to public format.
+func (c *PostgresPersistence) readItems(qResult pgx.Rows) ([]interface{}, error) {
+	defer qResult.Close()
+	items := make([]interface{}, 0)
+	for qResult.Next() {
+		items = append(items, c.Overrides.ConvertToPublic(qResult))
+	}
+	return items, qResult.Err()
 }
 
 // service function for return pointer on new prototype object for unmarshaling
@@ -0,0 +1,369 @@
+package persistence
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	cerr "github.com/pip-services3-go/pip-services3-commons-go/errors"
+)
+
+// Actions taken when an existing table differs from the declared schema
+const (
+	// The schema is not verified
+	SchemaDriftNone = "none"
+	// Differences are logged as warnings
+	SchemaDriftWarn = "warn"
+	// Missing or mismatched columns and indexes fail opening of the persistence
+	SchemaDriftFail = "fail"
+	// Missing columns and indexes are created, other differences are logged as warnings
+	SchemaDriftApply = "apply"
+)
+
+// PostgresSchemaDrift describes differences between the declared schema
+// of a persistence and the existing database table.
+type PostgresSchemaDrift struct {
+	// Declared columns that do not exist in the table.
+	MissingColumns []string `json:"missing_columns,omitempty"`
+	// Table columns that are not declared.
+	ExtraColumns []string `json:"extra_columns,omitempty"`
+	// Columns with types incompatible with declared ones, like "name: expected TEXT, actual integer".
+	MismatchedColumns []string `json:"mismatched_columns,omitempty"`
+	// Declared indexes that do not exist in the table.
+	MissingIndexes []string `json:"missing_indexes,omitempty"`
+	// Table indexes that are not declared, except primary keys.
+	ExtraIndexes []string `json:"extra_indexes,omitempty"`
+	// Indexes with different keys or uniqueness, like "name: expected UNIQUE (key), actual (key)".
+	MismatchedIndexes []string `json:"mismatched_indexes,omitempty"`
+	// Statements executed to add missing columns and indexes.
+	Applied []string `json:"applied,omitempty"`
+}
+
+// Checks if the table has missing or mismatched columns or indexes.
+// Extra columns and indexes do not break the persistence and are not considered.
+func (c *PostgresSchemaDrift) IsBreaking() bool {
+	return len(c.MissingColumns) > 0 || len(c.MismatchedColumns) > 0 ||
+		len(c.MissingIndexes) > 0 || len(c.MismatchedIndexes) > 0
+}
+
+// Checks if there are any differences between the declared schema and the table.
+func (c *PostgresSchemaDrift) HasDrift() bool {
+	return c.IsBreaking() || len(c.ExtraColumns) > 0 || len(c.ExtraIndexes) > 0
+}
+
+// Gets a human readable description of the differences.
+func (c *PostgresSchemaDrift) String() string {
+	parts := make([]string, 0)
+	add := func(title string, values []string) {
+		if len(values) > 0 {
+			parts = append(parts, title+": "+strings.Join(values, ", "))
+		}
+	}
+	add("missing columns", c.MissingColumns)
+	add("extra columns", c.ExtraColumns)
+	add("mismatched columns", c.MismatchedColumns)
+	add("missing indexes", c.MissingIndexes)
+	add("extra indexes", c.ExtraIndexes)
+	add("mismatched indexes", c.MismatchedIndexes)
+	return strings.Join(parts, "; ")
+}
+
+// Index declared with EnsureIndex
+type postgresIndex struct {
+	name      string
+	keys      []string
+	unique    bool
+	statement string
+}
+
+// Connection or pool used to run schema queries
+type postgresQuerier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+}
+
+// Compares the existing table with declared columns and indexes.
+// Columns are declared by EnsureTable or taken from the prototype struct,
+// indexes are declared by EnsureIndex.
+//   - correlationId 	(optional) transaction id to trace execution through call chain.
+// Returns differences between the declared schema and the table or error if the check failed.
+func (c *PostgresPersistence) VerifySchema(correlationId string) (drift *PostgresSchemaDrift, err error) {
+	client, err := c.getClient(correlationId)
+	if err != nil {
+		return nil, err
+	}
+	return c.verifySchema(context.Background(), client)
+}
+
+func (c *PostgresPersistence) verifySchema(ctx context.Context, querier postgresQuerier) (*PostgresSchemaDrift, error) {
+	drift := &PostgresSchemaDrift{}
+
+	columns, indexes := c.declaredSchema()
+
+	// Compare columns
+	if len(columns) > 0 {
+		actual, err := c.readTableColumns(ctx, querier)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range sortedKeys(columns) {
+			actualType, ok := actual[name]
+			if !ok {
+				drift.MissingColumns = append(drift.MissingColumns, name)
+			} else if !isColumnTypeCompatible(columns[name], actualType) {
+				drift.MismatchedColumns = append(drift.MismatchedColumns,
+					name+": expected "+columns[name]+", actual "+actualType)
+			}
+		}
+		for _, name := range sortedKeys(actual) {
+			if _, ok := columns[name]; !ok {
+				drift.ExtraColumns = append(drift.ExtraColumns, name)
+			}
+		}
+	}
+
+	// Compare indexes
+	actual, err := c.readTableIndexes(ctx, querier)
+	if err != nil {
+		return nil, err
+	}
+	declared := make(map[string]bool)
+	for _, index := range indexes {
+		declared[index.name] = true
+		existing, ok := actual[index.name]
+		if !ok {
+			drift.MissingIndexes = append(drift.MissingIndexes, index.name)
+			continue
+		}
+		if expected := describeIndex(index.unique, index.keys); existing != expected {
+			drift.MismatchedIndexes = append(drift.MismatchedIndexes,
+				index.name+": expected "+expected+", actual "+existing)
+		}
+	}
+	for _, name := range sortedKeys(actual) {
+		if !declared[name] {
+			drift.ExtraIndexes = append(drift.ExtraIndexes, name)
+		}
+	}
+
+	return drift, nil
+}
+
+// Verifies the existing table according to schema_drift option
+func (c *PostgresPersistence) checkSchemaDrift(ctx context.Context, correlationId string, querier postgresQuerier) error {
+	mode := strings.ToLower(c.schemaDrift)
+	if mode == "" || mode == SchemaDriftNone {
+		return nil
+	}
+
+	drift, err := c.verifySchema(ctx, querier)
+	if err != nil {
+		return err
+	}
+
+	if mode == SchemaDriftApply && (len(drift.MissingColumns) > 0 || len(drift.MissingIndexes) > 0) {
+		err = c.applySchemaDrift(ctx, correlationId, querier, drift)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !drift.HasDrift() {
+		return nil
+	}
+
+	if mode == SchemaDriftFail && drift.IsBreaking() {
+		return cerr.NewInternalError(correlationId, "SCHEMA_DRIFT",
+			"Table "+c.QuotedTableName()+" differs from declared schema: "+drift.String()).
+			WithDetails("drift", drift)
+	}
+
+	c.Logger.Warn(correlationId, "Table %s differs from declared schema: %s", c.QuotedTableName(), drift.String())
+	return nil
+}
+
+// Adds missing columns and indexes to the table
+func (c *PostgresPersistence) applySchemaDrift(ctx context.Context, correlationId string,
+	querier postgresQuerier, drift *PostgresSchemaDrift) error {
+	columns, indexes := c.declaredSchema()
+
+	statements := make([]string, 0)
+	for _, name := range drift.MissingColumns {
+		statements = append(statements, "ALTER TABLE "+c.QuotedTableName()+
+			" ADD COLUMN IF NOT EXISTS "+c.QuoteIdentifier(name)+" "+columns[name])
+	}
+	for _, name := range drift.MissingIndexes {
+		for _, index := range indexes {
+			if index.name == name {
+				statements = append(statements, index.statement)
+			}
+		}
+	}
+
+	for _, statement := range statements {
+		c.Logger.Info(correlationId, "Updating table %s: %s", c.QuotedTableName(), statement)
+		_, err := querier.Exec(ctx, statement)
+		if err != nil {
+			return cerr.NewInternalError(correlationId, "CREATE_SCHEMA_FAILED",
+				"Failed to update database objects for "+c.QuotedTableName()).
+				WithDetails("statement", statement).WithCause(err)
+		}
+		drift.Applied = append(drift.Applied, statement)
+	}
+	drift.MissingColumns = nil
+	drift.MissingIndexes = nil
+	return nil
+}
+
+// Gets declared columns with their SQL types and declared indexes.
+// Columns set by EnsureTable take precedence over prototype fields.
+func (c *PostgresPersistence) declaredSchema() (columns map[string]string, indexes []postgresIndex) {
+	c.lock.RLock()
+	indexes = append([]postgresIndex{}, c.schemaIndexes...)
+	columns = make(map[string]string)
+	for name, columnType := range c.schemaColumns {
+		columns[name] = columnType
+	}
+	c.lock.RUnlock()
+
+	if len(columns) == 0 {
+		columns = c.prototypeColumns()
+	}
+	return columns, indexes
+}
+
+// Reads table columns with their types from information_schema
+func (c *PostgresPersistence) readTableColumns(ctx context.Context, querier postgresQuerier) (map[string]string, error) {
+	rows, err := querier.Query(ctx, "SELECT column_name, data_type FROM information_schema.columns"+
+		" WHERE table_schema = COALESCE(NULLIF($1::text, ''), current_schema()) AND table_name = $2::text",
+		c.SchemaName, c.TableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]string)
+	for rows.Next() {
+		var name, dataType string
+		if err = rows.Scan(&name, &dataType); err != nil {
+			return nil, err
+		}
+		columns[name] = dataType
+	}
+	return columns, rows.Err()
+}
+
+// Reads table indexes described in normalized form like: UNIQUE (key1, key2).
+// Keys are read one by one with pg_get_indexdef, so expressions with commas
+// and INCLUDE columns are not mistaken for keys.
+// Indexes that back PRIMARY KEY and UNIQUE constraints, like "<table>_<column>_key", are skipped.
+func (c *PostgresPersistence) readTableIndexes(ctx context.Context, querier postgresQuerier) (map[string]string, error) {
+	rows, err := querier.Query(ctx, "SELECT i.relname, x.indisunique,"+
+		" ARRAY(SELECT pg_get_indexdef(x.indexrelid, k, true) FROM generate_series(1, x.indnkeyatts) AS k ORDER BY k)"+
+		" FROM pg_index x JOIN pg_class i ON i.oid = x.indexrelid"+
+		" JOIN pg_class t ON t.oid = x.indrelid JOIN pg_namespace n ON n.oid = t.relnamespace"+
+		" WHERE n.nspname = COALESCE(NULLIF($1::text, ''), current_schema()) AND t.relname = $2::text"+
+		" AND NOT EXISTS (SELECT 1 FROM pg_constraint k WHERE k.contype IN ('p', 'u') AND k.conindid = x.indexrelid)",
+		c.SchemaName, c.TableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	indexes := make(map[string]string)
+	for rows.Next() {
+		var name string
+		var unique bool
+		var keys []string
+		if err = rows.Scan(&name, &unique, &keys); err != nil {
+			return nil, err
+		}
+		indexes[name] = describeIndex(unique, keys)
+	}
+	return indexes, rows.Err()
+}
+
+// Compatible data types reported by information_schema for declared SQL types
+var compatibleColumnTypes = map[string][]string{
+	"TEXT":             {"text", "character varying", "character", "uuid", "USER-DEFINED"},
+	"VARCHAR":          {"text", "character varying", "character"},
+	"CHARACTER":        {"text", "character varying", "character"},
+	"UUID":             {"uuid", "text", "character varying"},
+	"BOOLEAN":          {"boolean"},
+	"SMALLINT":         {"smallint", "integer", "bigint", "numeric"},
+	"INTEGER":          {"smallint", "integer", "bigint", "numeric"},
+	"INT":              {"smallint", "integer", "bigint", "numeric"},
+	"BIGINT":           {"smallint", "integer", "bigint", "numeric"},
+	"SERIAL":           {"integer", "bigint"},
+	"BIGSERIAL":        {"bigint"},
+	"REAL":             {"real", "double precision", "numeric", "smallint", "integer", "bigint"},
+	"DOUBLE PRECISION": {"real", "double precision", "numeric", "smallint", "integer", "bigint"},
+	"NUMERIC":          {"numeric", "real", "double precision", "smallint", "integer", "bigint"},
+	"DECIMAL":          {"numeric", "real", "double precision", "smallint", "integer", "bigint"},
+	"TIMESTAMPTZ":      {"timestamp with time zone", "timestamp without time zone", "date"},
+	"TIMESTAMP":        {"timestamp with time zone", "timestamp without time zone", "date"},
+	"DATE":             {"date", "timestamp with time zone", "timestamp without time zone"},
+	"BYTEA":            {"bytea"},
+	"JSONB":            {"jsonb", "json", "ARRAY"},
+	"JSON":             {"jsonb", "json"},
+	"INTERVAL":         {"interval", "bigint"},
+	"INET":             {"inet", "cidr"},
+	"CIDR":             {"cidr", "inet"},
+}
+
+// Checks if actual column data type is compatible with the declared SQL type.
+// Unknown declared types are considered compatible.
+func isColumnTypeCompatible(declared string, actual string) bool {
+	declared = strings.ToUpper(strings.TrimSpace(declared))
+	// Remove modifiers and constraints, like VARCHAR(100) NOT NULL
+	if index := strings.Index(declared, "("); index > 0 {
+		declared = strings.TrimSpace(declared[:index])
+	}
+	for _, constraint := range []string{" NOT NULL", " NULL", " PRIMARY KEY", " UNIQUE", " DEFAULT", " REFERENCES", " CHECK"} {
+		if index := strings.Index(declared, constraint); index > 0 {
+			declared = strings.TrimSpace(declared[:index])
+		}
+	}
+	if strings.HasSuffix(declared, "[]") {
+		return actual == "ARRAY"
+	}
+	if declared == "CHARACTER VARYING" || declared == "CHAR" {
+		declared = "VARCHAR"
+	}
+
+	compatible, ok := compatibleColumnTypes[declared]
+	if !ok {
+		return true
+	}
+	for _, dataType := range compatible {
+		if dataType == actual {
+			return true
+		}
+	}
+	return false
+}
+
+// Describes index in normalized form like: UNIQUE (key1, key2)
+func describeIndex(unique bool, keys []string) string {
+	normalized := make([]string, 0, len(keys))
+	for _, key := range keys {
+		normalized = append(normalized, strings.ToLower(strings.ReplaceAll(strings.TrimSpace(key), "\"", "")))
+	}
+	sort.Strings(normalized)
+	result := "(" + strings.Join(normalized, ", ") + ")"
+	if unique {
+		result = "UNIQUE " + result
+	}
+	return result
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
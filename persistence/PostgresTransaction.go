@@ -0,0 +1,104 @@
+package persistence
+
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v4"
+	cerr "github.com/pip-services3-go/pip-services3-commons-go/errors"
+)
+
+/*
+Transaction started by PostgresPersistence.BeginTransaction.
+The transaction is registered as a running operation in the connection until it is committed or rolled back,
+so closing of the connection waits for it to complete.
+
+### Example ###
+
+    err := persistence.WithTransaction("123", func(tx *persist.PostgresTransaction) error {
+        item, err := persistence.GetOneByIdLocked("123", tx, "1", nil)
+        if err != nil {
+            return err
+        }
+        ...
+        return nil
+    })
+*/
+type PostgresTransaction struct {
+	pgx.Tx
+	ctx    context.Context
+	done   func()
+	closed int32
+}
+
+// Gets the operation context of the transaction.
+func (c *PostgresTransaction) Context() context.Context {
+	return c.ctx
+}
+
+// Commits the transaction and completes the connection operation.
+//   - ctx     operation context.
+// Returns error or nil when no error occured.
+func (c *PostgresTransaction) Commit(ctx context.Context) error {
+	defer c.close()
+	return c.Tx.Commit(ctx)
+}
+
+// Rolls back the transaction and completes the connection operation.
+// Rollback of a completed transaction does nothing.
+//   - ctx     operation context.
+// Returns error or nil when no error occured.
+func (c *PostgresTransaction) Rollback(ctx context.Context) error {
+	defer c.close()
+	err := c.Tx.Rollback(ctx)
+	if err == pgx.ErrTxClosed {
+		return nil
+	}
+	return err
+}
+
+// Marks the transaction as committed or rolled back and completes the connection operation once.
+func (c *PostgresTransaction) close() {
+	if atomic.CompareAndSwapInt32(&c.closed, 0, 1) && c.done != nil {
+		c.done()
+	}
+}
+
+// Checks if the transaction was committed or rolled back.
+func (c *PostgresTransaction) isClosed() bool {
+	return atomic.LoadInt32(&c.closed) != 0
+}
+
+// Checks that queries can be executed within the transaction.
+// Returns InvalidStateError with NO_TRANSACTION code when the transaction is nil,
+// including nil pointers of any transaction type, or TRANSACTION_CLOSED code
+// when the transaction is already committed or rolled back.
+func checkTransaction(correlationId string, tx pgx.Tx, target string) error {
+	if ptx, ok := tx.(*PostgresTransaction); ok {
+		if ptx == nil || ptx.Tx == nil {
+			tx = nil
+		} else if ptx.isClosed() {
+			return cerr.NewInvalidStateError(correlationId, "TRANSACTION_CLOSED",
+				"Transaction for "+target+" is already committed or rolled back")
+		}
+	}
+	if tx != nil {
+		if value := reflect.ValueOf(tx); value.Kind() == reflect.Ptr && value.IsNil() {
+			tx = nil
+		}
+	}
+	if tx == nil {
+		return cerr.NewInvalidStateError(correlationId, "NO_TRANSACTION",
+			"Locking read from "+target+" must be called within a transaction")
+	}
+	return nil
+}
+
+// Gets the context to execute queries within the transaction.
+func transactionContext(tx pgx.Tx) context.Context {
+	if ptx, ok := tx.(*PostgresTransaction); ok && ptx.ctx != nil {
+		return ptx.ctx
+	}
+	return context.TODO()
+}
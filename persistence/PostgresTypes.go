@@ -0,0 +1,331 @@
+package persistence
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"reflect"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+)
+
+// Mapping between Go types and PostgreSQL types.
+//
+// Values are passed to the driver as is, so it encodes them natively:
+//   - time.Time           TIMESTAMPTZ, TIMESTAMP, DATE
+//   - time.Duration       INTERVAL (or BIGINT with nanoseconds)
+//   - [16]byte, string    UUID
+//   - float64, string     NUMERIC, or decimal types that implement driver.Valuer and sql.Scanner.
+//                         No field type is mapped to NUMERIC, so NUMERIC columns are declared
+//                         only by tags like postgres:"type:NUMERIC(12,2)"
+//   - slices              arrays like TEXT[] or BIGINT[], or JSONB
+//   - net.IP, *net.IPNet  INET, CIDR
+//   - maps and structs    JSONB
+//
+// Column values are converted back to types of struct fields. Decimal types are expected
+// to implement sql.Scanner. Other values are converted through JSON as a fallback.
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	durationType      = reflect.TypeOf(time.Duration(0))
+	ipType            = reflect.TypeOf(net.IP{})
+	ipNetType         = reflect.TypeOf(net.IPNet{})
+	uuidType          = reflect.TypeOf([16]byte{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	valuerType        = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
+// SQL types of nullable types from database/sql
+var nullTypes = map[reflect.Type]string{
+	reflect.TypeOf(sql.NullString{}):  "TEXT",
+	reflect.TypeOf(sql.NullBool{}):    "BOOLEAN",
+	reflect.TypeOf(sql.NullInt32{}):   "INTEGER",
+	reflect.TypeOf(sql.NullInt64{}):   "BIGINT",
+	reflect.TypeOf(sql.NullFloat64{}): "DOUBLE PRECISION",
+	reflect.TypeOf(sql.NullTime{}):    "TIMESTAMPTZ",
+}
+
+// Gets a SQL column type for the Go type
+func sqlTypeOf(t reflect.Type) string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return "TIMESTAMPTZ"
+	case durationType:
+		return "INTERVAL"
+	case ipType:
+		return "INET"
+	case ipNetType:
+		return "CIDR"
+	}
+	if t.ConvertibleTo(uuidType) && t.Kind() == reflect.Array {
+		return "UUID"
+	}
+	if sqlType, ok := nullTypes[t]; ok {
+		return sqlType
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "TEXT"
+	case reflect.Bool:
+		return "BOOLEAN"
+	case reflect.Int8, reflect.Int16, reflect.Uint8:
+		return "SMALLINT"
+	case reflect.Int32, reflect.Uint16:
+		return "INTEGER"
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return "BIGINT"
+	case reflect.Float32:
+		return "REAL"
+	case reflect.Float64:
+		return "DOUBLE PRECISION"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "BYTEA"
+		}
+	}
+	return "JSONB"
+}
+
+// Gets a value of the field to pass to the driver
+func columnValueOf(field reflect.Value) (interface{}, error) {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return nil, nil
+		}
+		// Pointers to network addresses are encoded by the driver
+		if field.Type().Elem() == ipNetType {
+			return field.Interface(), nil
+		}
+		field = field.Elem()
+	}
+
+	fieldType := field.Type()
+	if fieldType == timeType || fieldType.Implements(valuerType) {
+		return field.Interface(), nil
+	}
+	if fieldType.Kind() == reflect.Array && fieldType.ConvertibleTo(uuidType) {
+		return field.Convert(uuidType).Interface(), nil
+	}
+
+	// Types with custom JSON representation are stored as before in their JSON form
+	if fieldType.Implements(jsonMarshalerType) {
+		buf, err := json.Marshal(field.Interface())
+		if err != nil {
+			return nil, err
+		}
+		var result interface{}
+		err = json.Unmarshal(buf, &result)
+		return result, err
+	}
+	return field.Interface(), nil
+}
+
+// Value decoded by the driver that can assign itself to Go types
+type assignableValue interface {
+	AssignTo(dst interface{}) error
+}
+
+// Assigns a column value decoded by the driver to the struct field.
+// Values are converted between compatible Go types, pgtype values are assigned by the driver
+// and other values are converted through JSON as a fallback.
+func assignColumnValue(target reflect.Value, value interface{}) error {
+	if !target.CanSet() {
+		return errors.New("field can not be set")
+	}
+
+	if target.Kind() == reflect.Ptr {
+		elem := reflect.New(target.Type().Elem())
+		if err := assignColumnValue(elem.Elem(), value); err != nil {
+			return err
+		}
+		target.Set(elem)
+		return nil
+	}
+
+	v := reflect.ValueOf(value)
+	targetType := target.Type()
+	if v.Type().AssignableTo(targetType) {
+		target.Set(v)
+		return nil
+	}
+	if isNumberKind(v.Kind()) && isNumberKind(target.Kind()) {
+		return assignNumber(target, v)
+	}
+	if v.Kind() == reflect.String && target.Kind() == reflect.String ||
+		v.Kind() == reflect.Array && v.Type().ConvertibleTo(targetType) {
+		target.Set(v.Convert(targetType))
+		return nil
+	}
+
+	// Network addresses
+	if ipNet, ok := value.(*net.IPNet); ok {
+		switch {
+		case targetType == ipType:
+			target.Set(reflect.ValueOf(ipNet.IP))
+			return nil
+		case targetType == ipNetType:
+			target.Set(reflect.ValueOf(*ipNet))
+			return nil
+		case target.Kind() == reflect.String:
+			target.SetString(formatIPNet(ipNet))
+			return nil
+		}
+	}
+
+	// UUIDs into strings
+	if uuid, ok := value.([16]byte); ok && target.Kind() == reflect.String {
+		target.SetString(formatUUID(uuid))
+		return nil
+	}
+
+	// Decimal and other types that can scan database values
+	if scanner, ok := target.Addr().Interface().(sql.Scanner); ok {
+		if err := scanner.Scan(driverValueOf(value)); err == nil {
+			return nil
+		}
+	}
+
+	// pgtype values like Numeric, Interval or arrays have AssignTo methods on pointers
+	pointer := reflect.New(v.Type())
+	pointer.Elem().Set(v)
+	if assignable, ok := pointer.Interface().(assignableValue); ok {
+		if err := assignable.AssignTo(target.Addr().Interface()); err == nil {
+			return nil
+		}
+	}
+
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf, target.Addr().Interface())
+}
+
+// Assigns a number to the numeric field.
+// Returns error when the number does not fit into the field or has a fraction for an integer field.
+func assignNumber(target reflect.Value, v reflect.Value) error {
+	overflow := false
+	switch target.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			overflow = target.OverflowInt(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			overflow = v.Uint() > math.MaxInt64 || target.OverflowInt(int64(v.Uint()))
+		default:
+			f := v.Float()
+			if f != math.Trunc(f) {
+				return fmt.Errorf("number %v has a fraction and can not be assigned to %s", f, target.Type())
+			}
+			overflow = f < math.MinInt64 || f >= math.MaxInt64 || target.OverflowInt(int64(f))
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			overflow = v.Int() < 0 || target.OverflowUint(uint64(v.Int()))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			overflow = target.OverflowUint(v.Uint())
+		default:
+			f := v.Float()
+			if f != math.Trunc(f) {
+				return fmt.Errorf("number %v has a fraction and can not be assigned to %s", f, target.Type())
+			}
+			overflow = f < 0 || f >= math.MaxUint64 || target.OverflowUint(uint64(f))
+		}
+	default:
+		if v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64 {
+			overflow = target.OverflowFloat(v.Float())
+		}
+	}
+	if overflow {
+		return fmt.Errorf("number %v overflows %s", v.Interface(), target.Type())
+	}
+	target.Set(v.Convert(target.Type()))
+	return nil
+}
+
+// Converts the current row into a map of column values.
+// Numeric values are converted into float64 and UUIDs into strings.
+func convertRowToMap(rows pgx.Rows) (map[string]interface{}, error) {
+	values, err := rows.Values()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]interface{}, len(values))
+	for index, field := range rows.FieldDescriptions() {
+		value := values[index]
+		switch v := value.(type) {
+		case pgtype.Numeric:
+			var number float64
+			if err = v.AssignTo(&number); err != nil {
+				return nil, err
+			}
+			value = number
+		case [16]byte:
+			value = formatUUID(v)
+		}
+		result[string(field.Name)] = value
+	}
+	return result, nil
+}
+
+// Converts a value decoded by the driver into a value accepted by sql.Scanner
+func driverValueOf(value interface{}) interface{} {
+	switch v := value.(type) {
+	case [16]byte:
+		return formatUUID(v)
+	case *net.IPNet:
+		return formatIPNet(v)
+	case pgtype.TextEncoder:
+		buf, err := v.EncodeText(nil, nil)
+		if err == nil {
+			return string(buf)
+		}
+	}
+	return value
+}
+
+// Formats UUID as xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx
+func formatUUID(uuid [16]byte) string {
+	buf := make([]byte, 36)
+	hex.Encode(buf[0:8], uuid[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], uuid[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], uuid[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], uuid[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], uuid[10:])
+	return string(buf)
+}
+
+// Formats network address as IP for single hosts or in CIDR notation for networks
+func formatIPNet(ipNet *net.IPNet) string {
+	ones, bits := ipNet.Mask.Size()
+	if ones == bits {
+		return ipNet.IP.String()
+	}
+	return ipNet.String()
+}
+
+func isNumberKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,285 @@
+package persistence
+
+import (
+	"encoding/json"
+	"strconv"
+
+	cerr "github.com/pip-services3-go/pip-services3-commons-go/errors"
+)
+
+// Kinds of atomic update operations
+const (
+	updateSet       = "set"
+	updateIncrement = "increment"
+	updateDecrement = "decrement"
+	updateMin       = "min"
+	updateMax       = "max"
+	updateAppend    = "append"
+	updateRemove    = "remove"
+	updateUnset     = "unset"
+)
+
+type postgresUpdateOperation struct {
+	kind   string
+	column string
+	path   []string
+	value  interface{}
+}
+
+/*
+Set of atomic field operations that are compiled into one parameterized UPDATE statement,
+so values are changed by the database without reading them first.
+
+Operations without a path change the column itself, arrays can be SQL arrays like TEXT[]
+or JSONB arrays when the column is mapped to JSONB in the prototype or is the data column
+of IdentifiableJsonPostgresPersistence. Numbers in JSONB columns are changed as JSON numbers.
+Operations with a path change a value inside a JSONB column, like a field in the data column
+of IdentifiableJsonPostgresPersistence. Several operations on the same column are applied in order.
+
+### Example ###
+
+    operations := persist.NewPostgresUpdateOperations().
+        Increment("count", 1).
+        Append("tags", "new").
+        SetPath("params", []string{"options", "color"}, "red")
+
+    item, err := persistence.UpdateByOperations("123", "1", operations)
+*/
+type PostgresUpdateOperations struct {
+	operations []*postgresUpdateOperation
+}
+
+// Creates a new empty set of update operations.
+func NewPostgresUpdateOperations() *PostgresUpdateOperations {
+	return &PostgresUpdateOperations{
+		operations: make([]*postgresUpdateOperation, 0),
+	}
+}
+
+func (c *PostgresUpdateOperations) add(kind string, column string, path []string, value interface{}) *PostgresUpdateOperations {
+	c.operations = append(c.operations, &postgresUpdateOperation{
+		kind:   kind,
+		column: column,
+		path:   path,
+		value:  value,
+	})
+	return c
+}
+
+// Gets the number of operations.
+func (c *PostgresUpdateOperations) Len() int {
+	return len(c.operations)
+}
+
+// Sets the column to a value.
+func (c *PostgresUpdateOperations) Set(column string, value interface{}) *PostgresUpdateOperations {
+	return c.add(updateSet, column, nil, value)
+}
+
+// Sets a value at the path inside the JSONB column. Missing objects on the path are created.
+// A nil value sets JSON null.
+func (c *PostgresUpdateOperations) SetPath(column string, path []string, value interface{}) *PostgresUpdateOperations {
+	return c.add(updateSet, column, path, value)
+}
+
+// Adds a value to the numeric column. NULL is treated as 0.
+func (c *PostgresUpdateOperations) Increment(column string, value interface{}) *PostgresUpdateOperations {
+	return c.add(updateIncrement, column, nil, value)
+}
+
+// Adds a value to the number at the path inside the JSONB column. Missing value is treated as 0.
+func (c *PostgresUpdateOperations) IncrementPath(column string, path []string, value interface{}) *PostgresUpdateOperations {
+	return c.add(updateIncrement, column, path, value)
+}
+
+// Subtracts a value from the numeric column. NULL is treated as 0.
+func (c *PostgresUpdateOperations) Decrement(column string, value interface{}) *PostgresUpdateOperations {
+	return c.add(updateDecrement, column, nil, value)
+}
+
+// Subtracts a value from the number at the path inside the JSONB column. Missing value is treated as 0.
+func (c *PostgresUpdateOperations) DecrementPath(column string, path []string, value interface{}) *PostgresUpdateOperations {
+	return c.add(updateDecrement, column, path, value)
+}
+
+// Sets the column to a value if the value is less than the current one.
+func (c *PostgresUpdateOperations) Min(column string, value interface{}) *PostgresUpdateOperations {
+	return c.add(updateMin, column, nil, value)
+}
+
+// Sets the number at the path inside the JSONB column to a value if the value is less than the current one.
+func (c *PostgresUpdateOperations) MinPath(column string, path []string, value interface{}) *PostgresUpdateOperations {
+	return c.add(updateMin, column, path, value)
+}
+
+// Sets the column to a value if the value is greater than the current one.
+func (c *PostgresUpdateOperations) Max(column string, value interface{}) *PostgresUpdateOperations {
+	return c.add(updateMax, column, nil, value)
+}
+
+// Sets the number at the path inside the JSONB column to a value if the value is greater than the current one.
+func (c *PostgresUpdateOperations) MaxPath(column string, path []string, value interface{}) *PostgresUpdateOperations {
+	return c.add(updateMax, column, path, value)
+}
+
+// Appends a value to the array column.
+func (c *PostgresUpdateOperations) Append(column string, value interface{}) *PostgresUpdateOperations {
+	return c.add(updateAppend, column, nil, value)
+}
+
+// Appends a value to the array at the path inside the JSONB column. Missing array is created.
+func (c *PostgresUpdateOperations) AppendPath(column string, path []string, value interface{}) *PostgresUpdateOperations {
+	return c.add(updateAppend, column, path, value)
+}
+
+// Removes all elements equal to the value from the array column.
+func (c *PostgresUpdateOperations) Remove(column string, value interface{}) *PostgresUpdateOperations {
+	return c.add(updateRemove, column, nil, value)
+}
+
+// Removes all elements equal to the value from the array at the path inside the JSONB column.
+func (c *PostgresUpdateOperations) RemovePath(column string, path []string, value interface{}) *PostgresUpdateOperations {
+	return c.add(updateRemove, column, path, value)
+}
+
+// Sets the column to NULL.
+func (c *PostgresUpdateOperations) Unset(column string) *PostgresUpdateOperations {
+	return c.add(updateUnset, column, nil, nil)
+}
+
+// Removes the key at the path from the JSONB column.
+func (c *PostgresUpdateOperations) UnsetPath(column string, path []string) *PostgresUpdateOperations {
+	return c.add(updateUnset, column, path, nil)
+}
+
+// Compiles operations into a SET clause like: "count"=COALESCE("count",0)+$2
+//   - quote     a function to quote column names
+//   - isJson    a function to check if the column has JSON type
+//   - offset    number of parameters that precede the operation values
+// Returns the clause and values of its parameters.
+func (c *PostgresUpdateOperations) compile(correlationId string, quote func(string) string,
+	isJson func(string) bool, offset int) (setParams string, values []interface{}, err error) {
+
+	values = make([]interface{}, 0, len(c.operations)*2)
+	param := func(value interface{}) string {
+		values = append(values, value)
+		return "$" + strconv.Itoa(offset+len(values))
+	}
+
+	columns := make([]string, 0, len(c.operations))
+	expressions := make(map[string]string, len(c.operations))
+	for _, operation := range c.operations {
+		expr, ok := expressions[operation.column]
+		if !ok {
+			expr = quote(operation.column)
+			columns = append(columns, operation.column)
+		}
+
+		if len(operation.path) == 0 && (!isJson(operation.column) ||
+			operation.kind == updateSet || operation.kind == updateUnset) {
+			expr = compileColumnOperation(operation, expr, param)
+		} else {
+			expr, err = compileJsonOperation(correlationId, operation, expr, param)
+			if err != nil {
+				return "", nil, err
+			}
+		}
+		expressions[operation.column] = expr
+	}
+
+	for index, column := range columns {
+		if index > 0 {
+			setParams += ","
+		}
+		setParams += quote(column) + "=" + expressions[column]
+	}
+	return setParams, values, nil
+}
+
+func compileColumnOperation(operation *postgresUpdateOperation, expr string, param func(interface{}) string) string {
+	switch operation.kind {
+	case updateIncrement:
+		return "COALESCE(" + expr + ",0)+" + param(operation.value)
+	case updateDecrement:
+		return "COALESCE(" + expr + ",0)-" + param(operation.value)
+	case updateMin:
+		return "LEAST(" + expr + "," + param(operation.value) + ")"
+	case updateMax:
+		return "GREATEST(" + expr + "," + param(operation.value) + ")"
+	case updateAppend:
+		return "array_append(" + expr + "," + param(operation.value) + ")"
+	case updateRemove:
+		return "array_remove(" + expr + "," + param(operation.value) + ")"
+	case updateUnset:
+		return "NULL"
+	}
+	return param(operation.value)
+}
+
+// Compiles operation on a value at the path inside JSONB column,
+// or on the whole JSONB column when the path is empty.
+func compileJsonOperation(correlationId string, operation *postgresUpdateOperation, expr string,
+	param func(interface{}) string) (string, error) {
+
+	jsonValue, err := json.Marshal(operation.value)
+	if err != nil {
+		return "", cerr.NewBadRequestError(correlationId, "INVALID_VALUE",
+			"Value for "+operation.column+" can not be converted to JSON").
+			WithDetails("path", operation.path).
+			WithCause(err)
+	}
+
+	if len(operation.path) == 0 {
+		value := param(string(jsonValue)) + "::jsonb"
+		return withJsonValue(expr, compileJsonValueOperation(operation.kind, "v", "(v #>> '{}')", value)), nil
+	}
+
+	path := param(operation.path) + "::text[]"
+	if operation.kind == updateUnset {
+		return expr + " #- " + path, nil
+	}
+
+	// jsonb_set only creates the last key, so missing parent objects are created first.
+	// Every step refers to the previous value once to keep SQL size linear in the number of operations.
+	expr = "COALESCE(" + expr + ",'{}'::jsonb)"
+	for index := 1; index < len(operation.path); index++ {
+		parent := param(operation.path[:index]) + "::text[]"
+		expr = withJsonValue(expr, "jsonb_set(v,"+parent+",COALESCE(v #> "+parent+",'{}'::jsonb),true)")
+	}
+	value := param(string(jsonValue)) + "::jsonb"
+
+	newValue := compileJsonValueOperation(operation.kind, "v #> "+path, "(v #>> "+path+")", value)
+	return withJsonValue(expr, "jsonb_set(v,"+path+","+newValue+",true)"), nil
+}
+
+// Compiles a new JSON value from the current one given as JSON and as text.
+func compileJsonValueOperation(kind string, current string, currentText string, value string) string {
+	switch kind {
+	case updateIncrement:
+		return "to_jsonb(COALESCE(" + currentText + "::numeric,0)+(" + value + " #>> '{}')::numeric)"
+	case updateDecrement:
+		return "to_jsonb(COALESCE(" + currentText + "::numeric,0)-(" + value + " #>> '{}')::numeric)"
+	case updateMin:
+		return "to_jsonb(LEAST(" + currentText + "::numeric,(" + value + " #>> '{}')::numeric))"
+	case updateMax:
+		return "to_jsonb(GREATEST(" + currentText + "::numeric,(" + value + " #>> '{}')::numeric))"
+	case updateAppend, updateRemove:
+		return compileJsonArrayOperation(kind, current, value)
+	}
+	return value
+}
+
+// Evaluates the expression over the JSON value named v,
+// so the value is written once in SQL however many times the expression uses it.
+func withJsonValue(value string, expr string) string {
+	return "(SELECT " + expr + " FROM (SELECT " + value + " AS v) AS s)"
+}
+
+// Compiles append or remove of the value to JSON array expression. Missing array is treated as empty.
+func compileJsonArrayOperation(kind string, array string, value string) string {
+	if kind == updateRemove {
+		return "COALESCE((SELECT jsonb_agg(e) FROM jsonb_array_elements(" + array + ") e" +
+			" WHERE e<>" + value + "),'[]'::jsonb)"
+	}
+	return "COALESCE(" + array + ",'[]'::jsonb)||jsonb_build_array(" + value + ")"
+}
@@ -1,10 +1,19 @@
 package test_connect
 
 import (
+	"context"
+	"errors"
+	"net"
 	"os"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/jackc/pgproto3/v2"
 	cconf "github.com/pip-services3-go/pip-services3-commons-go/config"
+	cerr "github.com/pip-services3-go/pip-services3-commons-go/errors"
+	cref "github.com/pip-services3-go/pip-services3-commons-go/refer"
+	"github.com/pip-services3-go/pip-services3-components-go/auth"
 	conn "github.com/pip-services3-go/pip-services3-postgres-go/connect"
 	"github.com/stretchr/testify/assert"
 )
@@ -46,6 +55,8 @@ func TestPostgresConnection(t *testing.T) {
 		"credential.username", postgresUser,
 		"credential.password", postgresPassword,
 		"options.max_pool_size", 10,
+		"options.statement_timeout", 30000,
+		"options.application_name", "pip-services-test",
 	)
 
 	connection = conn.NewPostgresConnection()
@@ -57,4 +68,522 @@ func TestPostgresConnection(t *testing.T) {
 	assert.NotNil(t, connection.GetConnection())
 	assert.NotNil(t, connection.GetDatabaseName())
 	assert.NotEqual(t, "", connection.GetDatabaseName())
+
+	var appName, statementTimeout string
+	err = connection.GetConnection().QueryRow(context.Background(),
+		"SELECT current_setting('application_name'), current_setting('statement_timeout')").Scan(&appName, &statementTimeout)
+	assert.Nil(t, err)
+	assert.Equal(t, "pip-services-test", appName)
+	assert.Equal(t, "30s", statementTimeout)
+}
+
+func TestPostgresConnectionHealthNotOpened(t *testing.T) {
+	connection := conn.NewPostgresConnection()
+	connection.Configure(cconf.NewConfigParamsFromTuples(
+		"connection.host", "localhost",
+		"connection.port", 5432,
+		"connection.database", "test",
+	))
+
+	err := connection.Ping("")
+	assert.NotNil(t, err)
+
+	status, err := connection.CheckHealth("")
+	assert.NotNil(t, err)
+	assert.NotNil(t, status)
+	assert.False(t, status.Healthy)
+	assert.NotEqual(t, "", status.Error)
+}
+
+func TestPostgresConnectionPoolOptions(t *testing.T) {
+	connection := conn.NewPostgresConnection()
+	connection.Configure(cconf.NewConfigParamsFromTuples(
+		"connection.host", "localhost",
+		"connection.port", 5432,
+		"connection.database", "test",
+		"options.max_pool_size", 7,
+		"options.min_pool_size", 0,
+		"options.idle_timeout", 20000,
+		"options.max_conn_lifetime", 600000,
+		"options.max_conn_lifetime_jitter", 1000,
+		"options.health_check_period", 15000,
+		"options.lazy_connect", true,
+	))
+
+	err := connection.Open("")
+	assert.Nil(t, err)
+	defer connection.Close("")
+
+	config := connection.GetConnection().Config()
+	assert.Equal(t, int32(7), config.MaxConns)
+	assert.Equal(t, 20*time.Second, config.MaxConnIdleTime)
+	assert.Equal(t, 10*time.Minute, config.MaxConnLifetime)
+	assert.Equal(t, time.Second, config.MaxConnLifetimeJitter)
+	assert.Equal(t, 15*time.Second, config.HealthCheckPeriod)
+	assert.True(t, config.LazyConnect)
+}
+
+func TestPostgresConnectionInvalidPoolOptions(t *testing.T) {
+	connection := conn.NewPostgresConnection()
+	connection.Configure(cconf.NewConfigParamsFromTuples(
+		"connection.host", "localhost",
+		"connection.port", 5432,
+		"connection.database", "test",
+		"options.max_pool_size", 2,
+		"options.min_pool_size", 5,
+		"options.lazy_connect", true,
+	))
+
+	err := connection.Open("")
+	assert.NotNil(t, err)
+	assert.False(t, connection.IsOpen())
+}
+
+func TestPostgresConnectionInvalidSessionOptions(t *testing.T) {
+	connection := conn.NewPostgresConnection()
+	connection.Configure(cconf.NewConfigParamsFromTuples(
+		"connection.host", "localhost",
+		"connection.port", 5432,
+		"connection.database", "test",
+		"options.statement_timeout", -1,
+		"options.lazy_connect", true,
+	))
+
+	err := connection.Open("")
+	assert.NotNil(t, err)
+	assert.False(t, connection.IsOpen())
+}
+
+func TestPostgresConnectionReplicas(t *testing.T) {
+	connection := conn.NewPostgresConnection()
+	connection.Configure(cconf.NewConfigParamsFromTuples(
+		"connections.primary.host", "localhost",
+		"connections.primary.port", 5432,
+		"connections.primary.database", "test",
+		"connections.replica1.host", "localhost",
+		"connections.replica1.port", 5433,
+		"connections.replica1.role", "replica",
+		"connections.replica2.host", "localhost",
+		"connections.replica2.port", 5434,
+		"connections.replica2.role", "replica",
+		"options.lazy_connect", true,
+	))
+
+	err := connection.Open("")
+	assert.Nil(t, err)
+	defer connection.Close("")
+
+	assert.Len(t, connection.Replicas, 2)
+
+	// Reads are balanced between replicas
+	read1 := connection.GetReadConnection()
+	read2 := connection.GetReadConnection()
+	assert.NotEqual(t, connection.GetConnection(), read1)
+	assert.NotEqual(t, connection.GetConnection(), read2)
+	assert.NotEqual(t, read1, read2)
+
+	// Failed replicas are excluded
+	connection.MarkReplicaFailed("", read1)
+	assert.Equal(t, read2, connection.GetReadConnection())
+	assert.Equal(t, read2, connection.GetReadConnection())
+
+	// Reads fall back to the primary
+	connection.MarkReplicaFailed("", read2)
+	assert.Equal(t, connection.GetConnection(), connection.GetReadConnection())
+}
+
+func TestPostgresConnectionReconnectAttempts(t *testing.T) {
+	connection := conn.NewPostgresConnection()
+	connection.Configure(cconf.NewConfigParamsFromTuples(
+		"connection.host", "localhost",
+		"connection.port", 1,
+		"connection.database", "test",
+		"options.connect_timeout", 1000,
+		"options.auto_reconnect", true,
+		"options.reconnect_attempts", 3,
+		"options.reconnect_delay", 10,
+	))
+
+	states := make([]conn.PostgresConnectionState, 0)
+	connection.AddStateListener(func(state conn.PostgresConnectionState, err error) {
+		states = append(states, state)
+	})
+
+	start := time.Now()
+	err := connection.Open("")
+	assert.NotNil(t, err)
+	assert.False(t, connection.IsOpen())
+	// Two delays between three attempts: 10 ms and 20 ms
+	assert.True(t, time.Since(start) >= 30*time.Millisecond)
+	assert.Equal(t, []conn.PostgresConnectionState{conn.PostgresConnectionConnecting, conn.PostgresConnectionFailed}, states)
+	assert.Equal(t, conn.PostgresConnectionFailed, connection.GetState())
+}
+
+func TestPostgresConnectionPasswordProvider(t *testing.T) {
+	connection := conn.NewPostgresConnection()
+	connection.Configure(cconf.NewConfigParamsFromTuples(
+		"connection.host", "localhost",
+		"connection.port", 1,
+		"connection.database", "test",
+		"credential.username", "postgres",
+		"credential.password", "postgres",
+	))
+
+	calls := 0
+	connection.SetPasswordProvider(func(ctx context.Context) (string, error) {
+		calls++
+		return "", errors.New("token is not available")
+	})
+
+	err := connection.Open("")
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Contains(t, err.Error(), "Connection to postgres failed")
+}
+
+func TestPostgresConnectionOpenErrors(t *testing.T) {
+	// Missing host
+	connection := conn.NewPostgresConnection()
+	connection.Configure(cconf.NewConfigParamsFromTuples(
+		"connection.database", "test",
+	))
+
+	err := connection.Open("")
+	assert.NotNil(t, err)
+	appErr, ok := err.(*cerr.ApplicationError)
+	assert.True(t, ok)
+	assert.Equal(t, cerr.Misconfiguration, appErr.Category)
+	assert.Equal(t, "NO_HOST", appErr.Code)
+
+	// Unreachable server, password must not leak
+	connection = conn.NewPostgresConnection()
+	connection.Configure(cconf.NewConfigParamsFromTuples(
+		"connection.host", "localhost",
+		"connection.port", 1,
+		"connection.database", "test",
+		"credential.username", "postgres",
+		"credential.password", "secret",
+	))
+
+	err = connection.Open("")
+	assert.NotNil(t, err)
+	appErr, ok = err.(*cerr.ApplicationError)
+	assert.True(t, ok)
+	assert.Equal(t, "CONNECT_FAILED", appErr.Code)
+	assert.NotContains(t, appErr.Cause, "secret")
+}
+
+func TestPostgresConnectionSharedUsers(t *testing.T) {
+	connection := conn.NewPostgresConnection()
+	connection.Configure(cconf.NewConfigParamsFromTuples(
+		"connection.host", "localhost",
+		"connection.port", 5432,
+		"connection.database", "test",
+		"options.lazy_connect", true,
+	))
+
+	err := connection.Open("")
+	assert.Nil(t, err)
+	pool := connection.GetConnection()
+
+	// Second open shares the same pool
+	err = connection.Open("")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, connection.GetUsersCount())
+	assert.True(t, pool == connection.GetConnection())
+
+	// The pool stays open while it is used
+	err = connection.Close("")
+	assert.Nil(t, err)
+	assert.True(t, connection.IsOpen())
+	assert.Equal(t, 1, connection.GetUsersCount())
+
+	err = connection.Close("")
+	assert.Nil(t, err)
+	assert.False(t, connection.IsOpen())
+	assert.Equal(t, 0, connection.GetUsersCount())
+
+	// Forced shutdown closes the pool for all users
+	connection.Open("")
+	connection.Open("")
+	aborted, err := connection.Shutdown("")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, aborted)
+	assert.False(t, connection.IsOpen())
+	assert.Equal(t, conn.PostgresConnectionClosed, connection.GetState())
+
+	err = connection.Close("")
+	assert.Nil(t, err)
+}
+
+func TestPostgresConnectionDrain(t *testing.T) {
+	connection := conn.NewPostgresConnection()
+	connection.Configure(cconf.NewConfigParamsFromTuples(
+		"connection.host", "localhost",
+		"connection.port", 5432,
+		"connection.database", "test",
+		"options.lazy_connect", true,
+		"options.drain_timeout", 100,
+	))
+
+	err := connection.Open("")
+	assert.Nil(t, err)
+
+	// Operation completed within drain timeout
+	_, done, err := connection.BeginOperation(context.Background(), "")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, connection.GetOperationsCount())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		done()
+	}()
+	aborted, err := connection.Drain("")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, aborted)
+
+	// New operations are rejected while closing
+	_, _, err = connection.BeginOperation(context.Background(), "")
+	assert.NotNil(t, err)
+	appErr, ok := err.(*cerr.ApplicationError)
+	assert.True(t, ok)
+	assert.Equal(t, "CONNECTION_CLOSING", appErr.Code)
+
+	err = connection.Close("")
+	assert.Nil(t, err)
+
+	// Operation not completed within drain timeout is canceled
+	err = connection.Open("")
+	assert.Nil(t, err)
+
+	ctx, done, err := connection.BeginOperation(context.Background(), "")
+	assert.Nil(t, err)
+	aborted, err = connection.Drain("")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, aborted)
+	assert.NotNil(t, ctx.Err())
+	done()
+	assert.Equal(t, 0, connection.GetOperationsCount())
+
+	err = connection.Close("")
+	assert.Nil(t, err)
+
+	// Close reports operations canceled on close
+	err = connection.Open("")
+	assert.Nil(t, err)
+
+	ctx, done, err = connection.BeginOperation(context.Background(), "")
+	assert.Nil(t, err)
+	aborted, err = connection.CloseWithResult("")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, aborted)
+	assert.NotNil(t, ctx.Err())
+	assert.False(t, connection.IsOpen())
+	done()
+
+	_, _, err = connection.BeginOperation(context.Background(), "")
+	assert.NotNil(t, err)
+}
+
+// Server that only authenticates clients with the current password
+// and answers every simple query with an empty response
+type fakeAuthServer struct {
+	listener net.Listener
+	lock     sync.Mutex
+	password string
+	failures int
+	down     bool
+}
+
+func startFakeAuthServer(t *testing.T, password string) *fakeAuthServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fakeAuthServer{listener: listener, password: password}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *fakeAuthServer) setPassword(password string) {
+	s.lock.Lock()
+	s.password = password
+	s.lock.Unlock()
+}
+
+func (s *fakeAuthServer) setDown(down bool) {
+	s.lock.Lock()
+	s.down = down
+	s.lock.Unlock()
+}
+
+func (s *fakeAuthServer) isDown() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.down
+}
+
+func (s *fakeAuthServer) getFailures() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.failures
+}
+
+func (s *fakeAuthServer) serve(conn net.Conn) {
+	defer conn.Close()
+	if s.isDown() {
+		return
+	}
+	backend := pgproto3.NewBackend(pgproto3.NewChunkReader(conn), conn)
+
+	msg, err := backend.ReceiveStartupMessage()
+	if _, ok := msg.(*pgproto3.SSLRequest); ok {
+		conn.Write([]byte("N"))
+		msg, err = backend.ReceiveStartupMessage()
+	}
+	if _, ok := msg.(*pgproto3.StartupMessage); !ok || err != nil {
+		return
+	}
+
+	backend.Send(&pgproto3.AuthenticationCleartextPassword{})
+	msg, err = backend.Receive()
+	password, ok := msg.(*pgproto3.PasswordMessage)
+	if !ok || err != nil {
+		return
+	}
+	s.lock.Lock()
+	valid := password.Password == s.password
+	if !valid {
+		s.failures++
+	}
+	s.lock.Unlock()
+	if !valid {
+		backend.Send(&pgproto3.ErrorResponse{Severity: "FATAL", Code: "28P01", Message: "password authentication failed"})
+		return
+	}
+
+	backend.Send(&pgproto3.AuthenticationOk{})
+	backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+	for {
+		msg, err = backend.Receive()
+		if _, ok := msg.(*pgproto3.Query); !ok || err != nil || s.isDown() {
+			return
+		}
+		backend.Send(&pgproto3.EmptyQueryResponse{})
+		backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+	}
+}
+
+func TestPostgresConnectionRotatedPassword(t *testing.T) {
+	server := startFakeAuthServer(t, "old")
+	defer server.listener.Close()
+
+	store := auth.NewEmptyMemoryCredentialStore()
+	store.Store("", "postgres", auth.NewCredentialParamsFromTuples("username", "postgres", "password", "old"))
+
+	connection := conn.NewPostgresConnection()
+	connection.Configure(cconf.NewConfigParamsFromTuples(
+		"connection.host", "127.0.0.1",
+		"connection.port", server.listener.Addr().(*net.TCPAddr).Port,
+		"connection.database", "test",
+		"connection.sslmode", "disable",
+		"credential.store_key", "postgres",
+	))
+	connection.SetReferences(cref.NewReferencesFromTuples(
+		cref.NewDescriptor("pip-services", "credential_store", "memory", "default", "1.0"), store,
+	))
+
+	err := connection.Open("")
+	if err != nil {
+		t.Error("Error opened connection", err)
+		return
+	}
+	defer connection.Close("")
+
+	// Password is rotated while the pool keeps an opened connection
+	server.setPassword("new")
+	store.Store("", "postgres", auth.NewCredentialParamsFromTuples("username", "postgres", "password", "new"))
+
+	pool := connection.GetConnection()
+	opened, err := pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	defer opened.Release()
+
+	// New physical connection fails with cached password and invalidates it
+	_, err = pool.Acquire(context.Background())
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, server.getFailures())
+
+	// Next physical connection resolves the new password
+	rotated, err := pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	if rotated != nil {
+		rotated.Release()
+	}
+	assert.Equal(t, 1, server.getFailures())
+}
+
+func TestPostgresConnectionReplicaRecovery(t *testing.T) {
+	primary := startFakeAuthServer(t, "secret")
+	defer primary.listener.Close()
+	replica := startFakeAuthServer(t, "secret")
+	defer replica.listener.Close()
+
+	connection := conn.NewPostgresConnection()
+	connection.Configure(cconf.NewConfigParamsFromTuples(
+		"connections.primary.host", "127.0.0.1",
+		"connections.primary.port", primary.listener.Addr().(*net.TCPAddr).Port,
+		"connections.primary.database", "test",
+		"connections.primary.sslmode", "disable",
+		"connections.replica1.host", "127.0.0.1",
+		"connections.replica1.port", replica.listener.Addr().(*net.TCPAddr).Port,
+		"connections.replica1.database", "test",
+		"connections.replica1.sslmode", "disable",
+		"connections.replica1.role", "replica",
+		"credential.username", "postgres",
+		"credential.password", "secret",
+		"options.auto_reconnect", true,
+		"options.reconnect_check_interval", 20,
+		"options.replica_retry_timeout", 60000,
+	))
+
+	err := connection.Open("")
+	if err != nil {
+		t.Error("Error opened connection", err)
+		return
+	}
+	defer connection.Close("")
+
+	oldReplica := connection.GetReadConnection()
+	assert.True(t, connection.GetConnection() != oldReplica)
+
+	waitFor := func(condition func() bool) bool {
+		for start := time.Now(); time.Since(start) < 3*time.Second; time.Sleep(10 * time.Millisecond) {
+			if condition() {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Replica that stopped responding is excluded from reading and rebuilt
+	replica.setDown(true)
+	assert.True(t, waitFor(func() bool {
+		return connection.GetReadConnection() == connection.GetConnection()
+	}))
+
+	// Reads return to the rebuilt replica when it responds again
+	replica.setDown(false)
+	assert.True(t, waitFor(func() bool {
+		return connection.GetReadConnection() != connection.GetConnection()
+	}))
+	assert.True(t, oldReplica != connection.GetReadConnection())
+	assert.Equal(t, conn.PostgresConnectionConnected, connection.GetState())
 }
@@ -0,0 +1,74 @@
+package test
+
+import (
+	"testing"
+
+	cdata "github.com/pip-services3-go/pip-services3-commons-go/data"
+	"github.com/stretchr/testify/assert"
+)
+
+type DummyBulkPersistenceFixture struct {
+	dummies     []Dummy
+	persistence IDummyBulkPersistence
+}
+
+func NewDummyBulkPersistenceFixture(persistence IDummyBulkPersistence) *DummyBulkPersistenceFixture {
+	c := DummyBulkPersistenceFixture{}
+	c.dummies = []Dummy{
+		{Id: "", Key: "Key 1", Content: "Content 1"},
+		{Id: "", Key: "Key 2", Content: "Content 2"},
+		{Id: "", Key: "Key 3", Content: "Content 3"},
+	}
+	c.persistence = persistence
+	return &c
+}
+
+func (c *DummyBulkPersistenceFixture) TestBulkOperations(t *testing.T) {
+	for _, dummy := range c.dummies {
+		_, err := c.persistence.Create("", dummy)
+		if err != nil {
+			t.Errorf("Create method error %v", err)
+		}
+	}
+
+	// Update two dummies
+	count, err := c.persistence.UpdateByKeys("", []string{"Key 1", "Key 2"},
+		cdata.NewAnyValueMapFromTuples("content", "Updated Content"))
+	if err != nil {
+		t.Errorf("UpdateByKeys method error %v", err)
+	}
+	assert.Equal(t, int64(2), count)
+
+	// Update and return the dummies
+	items, err := c.persistence.UpdateByKeysReturning("", []string{"Key 2", "Key 3", "Key 4"},
+		cdata.NewAnyValueMapFromTuples("content", "Updated Content 2"))
+	if err != nil {
+		t.Errorf("UpdateByKeysReturning method error %v", err)
+	}
+	assert.Len(t, items, 2)
+	for _, item := range items {
+		assert.Equal(t, "Updated Content 2", item.Content)
+	}
+
+	// Delete and return the dummy
+	items, err = c.persistence.DeleteByKeysReturning("", []string{"Key 1"})
+	if err != nil {
+		t.Errorf("DeleteByKeysReturning method error %v", err)
+	}
+	assert.Len(t, items, 1)
+	assert.Equal(t, "Key 1", items[0].Key)
+	assert.Equal(t, "Updated Content", items[0].Content)
+
+	// Delete the rest
+	count, err = c.persistence.DeleteByKeys("", []string{"Key 1", "Key 2", "Key 3"})
+	if err != nil {
+		t.Errorf("DeleteByKeys method error %v", err)
+	}
+	assert.Equal(t, int64(2), count)
+
+	count, err = c.persistence.DeleteByKeys("", []string{"Key 1", "Key 2", "Key 3"})
+	if err != nil {
+		t.Errorf("DeleteByKeys method error %v", err)
+	}
+	assert.Equal(t, int64(0), count)
+}
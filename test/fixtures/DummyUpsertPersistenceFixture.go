@@ -0,0 +1,72 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type DummyUpsertPersistenceFixture struct {
+	dummy1      Dummy
+	dummy2      Dummy
+	persistence IDummyUpsertPersistence
+}
+
+func NewDummyUpsertPersistenceFixture(persistence IDummyUpsertPersistence) *DummyUpsertPersistenceFixture {
+	c := DummyUpsertPersistenceFixture{}
+	c.dummy1 = Dummy{Id: "", Key: "Key 11", Content: "Content 1"}
+	c.dummy2 = Dummy{Id: "", Key: "Key 2", Content: "Content 2"}
+	c.persistence = persistence
+	return &c
+}
+
+func (c *DummyUpsertPersistenceFixture) TestUpsertOperations(t *testing.T) {
+	dummy1, err := c.persistence.Create("", c.dummy1)
+	if err != nil {
+		t.Errorf("Create method error %v", err)
+	}
+	assert.NotEqual(t, "", dummy1.Id)
+
+	// Upsert the dummy with the same key (updating)
+	result, err := c.persistence.UpsertByKey("", Dummy{Key: c.dummy1.Key, Content: "Upserted Content 1"})
+	if err != nil {
+		t.Errorf("UpsertByKey method error %v", err)
+	}
+	assert.Equal(t, dummy1.Id, result.Id)
+	assert.Equal(t, dummy1.Key, result.Key)
+	assert.Equal(t, "Upserted Content 1", result.Content)
+
+	// Upsert the dummy with a new key (creating)
+	result, err = c.persistence.UpsertByKey("", c.dummy2)
+	if err != nil {
+		t.Errorf("UpsertByKey method error %v", err)
+	}
+	assert.NotEqual(t, "", result.Id)
+	assert.NotEqual(t, dummy1.Id, result.Id)
+	assert.Equal(t, c.dummy2.Key, result.Key)
+	assert.Equal(t, c.dummy2.Content, result.Content)
+
+	// Skip the dummy with existing key
+	result, created, err := c.persistence.CreateIfNotExists("", Dummy{Key: c.dummy1.Key, Content: "Skipped Content"})
+	if err != nil {
+		t.Errorf("CreateIfNotExists method error %v", err)
+	}
+	assert.False(t, created)
+	assert.Equal(t, Dummy{}, result)
+
+	result, err = c.persistence.GetOneById("", dummy1.Id)
+	if err != nil {
+		t.Errorf("GetOneById method error %v", err)
+	}
+	assert.Equal(t, "Upserted Content 1", result.Content)
+
+	// Create the dummy with a new key
+	result, created, err = c.persistence.CreateIfNotExists("", Dummy{Key: "Key 3", Content: "Content 3"})
+	if err != nil {
+		t.Errorf("CreateIfNotExists method error %v", err)
+	}
+	assert.True(t, created)
+	assert.NotEqual(t, "", result.Id)
+	assert.Equal(t, "Key 3", result.Key)
+	assert.Equal(t, "Content 3", result.Content)
+}
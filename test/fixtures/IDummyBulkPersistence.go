@@ -0,0 +1,11 @@
+package test
+
+import cdata "github.com/pip-services3-go/pip-services3-commons-go/data"
+
+type IDummyBulkPersistence interface {
+	Create(correlationId string, item Dummy) (result Dummy, err error)
+	UpdateByKeys(correlationId string, keys []string, data *cdata.AnyValueMap) (count int64, err error)
+	UpdateByKeysReturning(correlationId string, keys []string, data *cdata.AnyValueMap) (items []Dummy, err error)
+	DeleteByKeys(correlationId string, keys []string) (count int64, err error)
+	DeleteByKeysReturning(correlationId string, keys []string) (items []Dummy, err error)
+}
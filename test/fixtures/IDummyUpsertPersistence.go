@@ -0,0 +1,8 @@
+package test
+
+type IDummyUpsertPersistence interface {
+	GetOneById(correlationId string, id string) (item Dummy, err error)
+	Create(correlationId string, item Dummy) (result Dummy, err error)
+	UpsertByKey(correlationId string, item Dummy) (result Dummy, err error)
+	CreateIfNotExists(correlationId string, item Dummy) (result Dummy, created bool, err error)
+}
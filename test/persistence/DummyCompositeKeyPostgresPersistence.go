@@ -0,0 +1,139 @@
+package test
+
+import (
+	"reflect"
+
+	cdata "github.com/pip-services3-go/pip-services3-commons-go/data"
+	persist "github.com/pip-services3-go/pip-services3-postgres-go/persistence"
+	tf "github.com/pip-services3-go/pip-services3-postgres-go/test/fixtures"
+)
+
+type DummyKeyed struct {
+	TenantId string `json:"tenant_id"`
+	Id       string `json:"id"`
+	Key      string `json:"key"`
+	Content  string `json:"content"`
+}
+
+// Stores dummies of a single tenant in a table keyed by (tenant_id, id)
+type DummyCompositeKeyPostgresPersistence struct {
+	persist.CompositeKeyPostgresPersistence
+	tenantId string
+}
+
+func NewDummyCompositeKeyPostgresPersistence(tenantId string) *DummyCompositeKeyPostgresPersistence {
+	proto := reflect.TypeOf(DummyKeyed{})
+	c := &DummyCompositeKeyPostgresPersistence{tenantId: tenantId}
+	c.CompositeKeyPostgresPersistence = *persist.InheritCompositeKeyPostgresPersistence(c, proto,
+		"dummies_keyed", []string{"tenant_id", "id"})
+	return c
+}
+
+func (c *DummyCompositeKeyPostgresPersistence) DefineSchema() {
+	c.ClearSchema()
+	c.CompositeKeyPostgresPersistence.DefineSchema()
+	c.EnsureSchema("CREATE TABLE " + c.QuotedTableName() +
+		" (\"tenant_id\" TEXT, \"id\" TEXT, \"key\" TEXT, \"content\" TEXT, PRIMARY KEY (\"tenant_id\", \"id\"))")
+	c.EnsureIndex(c.TableName+"_key", map[string]string{"tenant_id": "1", "key": "1"}, map[string]string{"unique": "true"})
+}
+
+func (c *DummyCompositeKeyPostgresPersistence) toKeyed(item tf.Dummy) DummyKeyed {
+	return DummyKeyed{TenantId: c.tenantId, Id: item.Id, Key: item.Key, Content: item.Content}
+}
+
+func (c *DummyCompositeKeyPostgresPersistence) toDummy(value interface{}) (item tf.Dummy) {
+	if val, ok := value.(DummyKeyed); ok {
+		item = tf.Dummy{Id: val.Id, Key: val.Key, Content: val.Content}
+	}
+	return item
+}
+
+func (c *DummyCompositeKeyPostgresPersistence) toKeys(ids []string) [][]interface{} {
+	keys := make([][]interface{}, len(ids))
+	for i, id := range ids {
+		keys[i] = []interface{}{c.tenantId, id}
+	}
+	return keys
+}
+
+func (c *DummyCompositeKeyPostgresPersistence) Create(correlationId string, item tf.Dummy) (result tf.Dummy, err error) {
+	if item.Id == "" {
+		item.Id = cdata.IdGenerator.NextLong()
+	}
+	value, err := c.CompositeKeyPostgresPersistence.Create(correlationId, c.toKeyed(item))
+	return c.toDummy(value), err
+}
+
+func (c *DummyCompositeKeyPostgresPersistence) GetListByIds(correlationId string, ids []string) (items []tf.Dummy, err error) {
+	result, err := c.CompositeKeyPostgresPersistence.GetListByKeys(correlationId, c.toKeys(ids))
+	items = make([]tf.Dummy, len(result))
+	for i, v := range result {
+		items[i] = c.toDummy(v)
+	}
+	return items, err
+}
+
+func (c *DummyCompositeKeyPostgresPersistence) GetOneById(correlationId string, id string) (item tf.Dummy, err error) {
+	result, err := c.CompositeKeyPostgresPersistence.GetOneByKey(correlationId, []interface{}{c.tenantId, id})
+	return c.toDummy(result), err
+}
+
+func (c *DummyCompositeKeyPostgresPersistence) GetOneRandom(correlationId string) (item tf.Dummy, err error) {
+	result, err := c.CompositeKeyPostgresPersistence.GetOneRandom(correlationId, "tenant_id='"+c.tenantId+"'")
+	return c.toDummy(result), err
+}
+
+func (c *DummyCompositeKeyPostgresPersistence) Update(correlationId string, item tf.Dummy) (result tf.Dummy, err error) {
+	value, err := c.CompositeKeyPostgresPersistence.Update(correlationId, c.toKeyed(item))
+	return c.toDummy(value), err
+}
+
+func (c *DummyCompositeKeyPostgresPersistence) Set(correlationId string, item tf.Dummy) (result tf.Dummy, err error) {
+	value, err := c.CompositeKeyPostgresPersistence.Set(correlationId, c.toKeyed(item))
+	return c.toDummy(value), err
+}
+
+func (c *DummyCompositeKeyPostgresPersistence) UpdatePartially(correlationId string, id string, data *cdata.AnyValueMap) (item tf.Dummy, err error) {
+	result, err := c.CompositeKeyPostgresPersistence.UpdatePartially(correlationId, []interface{}{c.tenantId, id}, data)
+	return c.toDummy(result), err
+}
+
+func (c *DummyCompositeKeyPostgresPersistence) DeleteById(correlationId string, id string) (item tf.Dummy, err error) {
+	result, err := c.CompositeKeyPostgresPersistence.DeleteByKey(correlationId, []interface{}{c.tenantId, id})
+	return c.toDummy(result), err
+}
+
+func (c *DummyCompositeKeyPostgresPersistence) DeleteByIds(correlationId string, ids []string) (err error) {
+	_, err = c.CompositeKeyPostgresPersistence.DeleteByKeys(correlationId, c.toKeys(ids))
+	return err
+}
+
+func (c *DummyCompositeKeyPostgresPersistence) composeFilter(filter *cdata.FilterParams) string {
+	if filter == nil {
+		filter = cdata.NewEmptyFilterParams()
+	}
+
+	filterObj := "tenant_id='" + c.tenantId + "'"
+	key := filter.GetAsNullableString("Key")
+	if key != nil && *key != "" {
+		filterObj += " AND key='" + *key + "'"
+	}
+	return filterObj
+}
+
+func (c *DummyCompositeKeyPostgresPersistence) GetPageByFilter(correlationId string, filter *cdata.FilterParams, paging *cdata.PagingParams) (page *tf.DummyPage, err error) {
+	tempPage, err := c.CompositeKeyPostgresPersistence.GetPageByFilter(correlationId,
+		c.composeFilter(filter), paging, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]tf.Dummy, len(tempPage.Data))
+	for i, v := range tempPage.Data {
+		data[i] = c.toDummy(v)
+	}
+	return tf.NewDummyPage(tempPage.Total, data), nil
+}
+
+func (c *DummyCompositeKeyPostgresPersistence) GetCountByFilter(correlationId string, filter *cdata.FilterParams) (count int64, err error) {
+	return c.CompositeKeyPostgresPersistence.GetCountByFilter(correlationId, c.composeFilter(filter))
+}
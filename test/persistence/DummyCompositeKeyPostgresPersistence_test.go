@@ -0,0 +1,132 @@
+package test
+
+import (
+	"os"
+	"testing"
+
+	cconf "github.com/pip-services3-go/pip-services3-commons-go/config"
+	tf "github.com/pip-services3-go/pip-services3-postgres-go/test/fixtures"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDummyCompositeKeyValidation(t *testing.T) {
+	persistence := NewDummyCompositeKeyPostgresPersistence("tenant1")
+
+	// Keys are checked before queries are sent
+	item, err := persistence.CompositeKeyPostgresPersistence.GetOneByKey("", []interface{}{"tenant1"})
+	assert.NotNil(t, err)
+	assert.Nil(t, item)
+
+	item, err = persistence.CompositeKeyPostgresPersistence.DeleteByKey("", []interface{}{"tenant1", nil})
+	assert.NotNil(t, err)
+	assert.Nil(t, item)
+
+	item, err = persistence.CompositeKeyPostgresPersistence.GetOneByKey("", []interface{}{"tenant1", ""})
+	assert.NotNil(t, err)
+	assert.Nil(t, item)
+
+	item, err = persistence.CompositeKeyPostgresPersistence.DeleteByKey("", []interface{}{"", "1"})
+	assert.NotNil(t, err)
+	assert.Nil(t, item)
+
+	item, err = persistence.CompositeKeyPostgresPersistence.Set("", DummyKeyed{Id: "1", Key: "Key 1"})
+	assert.NotNil(t, err)
+	assert.Nil(t, item)
+
+	key := persistence.GetKey(DummyKeyed{TenantId: "tenant1", Id: "1"})
+	assert.Equal(t, []interface{}{"tenant1", "1"}, key)
+}
+
+func TestDummyCompositeKeyPostgresPersistence(t *testing.T) {
+
+	postgresUri := os.Getenv("POSTGRES_URI")
+	postgresHost := os.Getenv("POSTGRES_HOST")
+	if postgresHost == "" {
+		postgresHost = "localhost"
+	}
+
+	postgresPort := os.Getenv("POSTGRES_PORT")
+	if postgresPort == "" {
+		postgresPort = "5432"
+	}
+
+	postgresDatabase := os.Getenv("POSTGRES_DB")
+	if postgresDatabase == "" {
+		postgresDatabase = "test"
+	}
+
+	postgresUser := os.Getenv("POSTGRES_USER")
+	if postgresUser == "" {
+		postgresUser = "postgres"
+	}
+	postgresPassword := os.Getenv("POSTGRES_PASSWORD")
+	if postgresPassword == "" {
+		postgresPassword = "postgres#"
+	}
+
+	if postgresUri == "" && postgresHost == "" {
+		panic("Connection params not set")
+	}
+
+	dbConfig := cconf.NewConfigParamsFromTuples(
+		"connection.uri", postgresUri,
+		"connection.host", postgresHost,
+		"connection.port", postgresPort,
+		"connection.database", postgresDatabase,
+		"credential.username", postgresUser,
+		"credential.password", postgresPassword,
+	)
+
+	persistence := NewDummyCompositeKeyPostgresPersistence("tenant1")
+	fixture := *tf.NewDummyPersistenceFixture(persistence)
+	persistence.Configure(dbConfig)
+
+	// Rows of another tenant with the same ids must not be affected
+	other := NewDummyCompositeKeyPostgresPersistence("tenant2")
+	other.Configure(dbConfig)
+
+	opnErr := persistence.Open("")
+	if opnErr != nil {
+		t.Error("Error opened persistence", opnErr)
+		return
+	}
+	defer persistence.Close("")
+
+	opnErr = other.Open("")
+	if opnErr != nil {
+		t.Error("Error opened persistence", opnErr)
+		return
+	}
+	defer other.Close("")
+
+	opnErr = persistence.Clear("")
+	if opnErr != nil {
+		t.Error("Error cleaned persistence", opnErr)
+		return
+	}
+
+	otherDummy, err := other.Create("", tf.Dummy{Id: "New_id", Key: "Key 11", Content: "Other Content"})
+	assert.Nil(t, err)
+
+	t.Run("DummyCompositeKeyPostgresPersistence:CRUD", fixture.TestCrudOperations)
+
+	result, err := other.GetOneById("", otherDummy.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, otherDummy, result)
+
+	opnErr = persistence.Clear("")
+	if opnErr != nil {
+		t.Error("Error cleaned persistence", opnErr)
+		return
+	}
+
+	t.Run("DummyCompositeKeyPostgresPersistence:Batch", fixture.TestBatchOperations)
+
+	opnErr = persistence.Clear("")
+	if opnErr != nil {
+		t.Error("Error cleaned persistence", opnErr)
+		return
+	}
+
+	t.Run("DummyCompositeKeyPostgresPersistence:Random", fixture.TestRandomOperation)
+}
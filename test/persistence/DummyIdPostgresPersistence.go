@@ -0,0 +1,72 @@
+package test
+
+import (
+	"reflect"
+
+	persist "github.com/pip-services3-go/pip-services3-postgres-go/persistence"
+)
+
+type DummyNumId struct {
+	Id      int64  `json:"num" postgres:"type:BIGINT GENERATED BY DEFAULT AS IDENTITY;primarykey"`
+	Key     string `json:"key"`
+	Content string `json:"content"`
+}
+
+type DummyUuidId struct {
+	Id      string `json:"id" postgres:"type:UUID;default:gen_random_uuid()"`
+	Key     string `json:"key"`
+	Content string `json:"content"`
+}
+
+type DummyNumIdPostgresPersistence struct {
+	persist.IdentifiablePostgresPersistence
+}
+
+func NewDummyNumIdPostgresPersistence() *DummyNumIdPostgresPersistence {
+	proto := reflect.TypeOf(DummyNumId{})
+	c := &DummyNumIdPostgresPersistence{}
+	c.IdentifiablePostgresPersistence = *persist.InheritIdentifiablePostgresPersistence(c, proto, "dummies_num_id")
+	c.IdColumn = "num"
+	return c
+}
+
+func (c *DummyNumIdPostgresPersistence) DefineSchema() {
+	c.ClearSchema()
+	c.IdentifiablePostgresPersistence.DefineSchema()
+	c.EnsureTableFromPrototype()
+}
+
+type DummyUuidIdPostgresPersistence struct {
+	persist.IdentifiablePostgresPersistence
+}
+
+func NewDummyUuidIdPostgresPersistence() *DummyUuidIdPostgresPersistence {
+	proto := reflect.TypeOf(DummyUuidId{})
+	c := &DummyUuidIdPostgresPersistence{}
+	c.IdentifiablePostgresPersistence = *persist.InheritIdentifiablePostgresPersistence(c, proto, "dummies_uuid_id")
+	c.IdStrategy = persist.IdStrategyRandomUuid
+	return c
+}
+
+func (c *DummyUuidIdPostgresPersistence) DefineSchema() {
+	c.ClearSchema()
+	c.IdentifiablePostgresPersistence.DefineSchema()
+	c.EnsureTableFromPrototype()
+}
+
+type DummyJsonNumIdPostgresPersistence struct {
+	persist.IdentifiableJsonPostgresPersistence
+}
+
+func NewDummyJsonNumIdPostgresPersistence() *DummyJsonNumIdPostgresPersistence {
+	proto := reflect.TypeOf(DummyNumId{})
+	c := &DummyJsonNumIdPostgresPersistence{}
+	c.IdentifiableJsonPostgresPersistence = *persist.InheritIdentifiableJsonPostgresPersistence(c, proto, "dummies_json_num_id")
+	return c
+}
+
+func (c *DummyJsonNumIdPostgresPersistence) DefineSchema() {
+	c.ClearSchema()
+	c.IdentifiableJsonPostgresPersistence.DefineSchema()
+	c.EnsureTable("BIGINT GENERATED ALWAYS AS IDENTITY", "")
+}
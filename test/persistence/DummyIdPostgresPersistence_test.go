@@ -0,0 +1,257 @@
+package test
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"strconv"
+	"testing"
+
+	cconf "github.com/pip-services3-go/pip-services3-commons-go/config"
+	cdata "github.com/pip-services3-go/pip-services3-commons-go/data"
+	tf "github.com/pip-services3-go/pip-services3-postgres-go/test/fixtures"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDummyIdStrategyConfig(t *testing.T) {
+	persistence := NewDummyPostgresPersistence()
+	persistence.Configure(cconf.NewConfigParamsFromTuples(
+		"options.id_column", "key",
+		"options.id_strategy", "unknown",
+	))
+	assert.Equal(t, "key", persistence.IdColumn)
+
+	// Unknown strategy fails before connecting
+	err := persistence.Open("")
+	assert.NotNil(t, err)
+	assert.False(t, persistence.IsOpen())
+
+	// Client generated ids require string id field
+	numPersistence := NewDummyNumIdPostgresPersistence()
+	numPersistence.Configure(cconf.NewConfigParamsFromTuples("options.id_strategy", "uuid4"))
+	result, err := numPersistence.Create("", DummyNumId{Key: "Key 1"})
+	assert.NotNil(t, err)
+	assert.Nil(t, result)
+}
+
+func TestDummyEmptyIds(t *testing.T) {
+	persistence := NewDummyPostgresPersistence()
+
+	// Empty lists of ids are not sent to the database
+	items, err := persistence.IdentifiablePostgresPersistence.GetListByIds("", []interface{}{})
+	assert.Nil(t, err)
+	assert.Len(t, items, 0)
+
+	items, err = persistence.GetListByIdsLocked("", nil, []interface{}{}, nil)
+	assert.Nil(t, err)
+	assert.Len(t, items, 0)
+}
+
+func TestDummyIdPostgresPersistence(t *testing.T) {
+
+	postgresUri := os.Getenv("POSTGRES_URI")
+	postgresHost := os.Getenv("POSTGRES_HOST")
+	if postgresHost == "" {
+		postgresHost = "localhost"
+	}
+
+	postgresPort := os.Getenv("POSTGRES_PORT")
+	if postgresPort == "" {
+		postgresPort = "5432"
+	}
+
+	postgresDatabase := os.Getenv("POSTGRES_DB")
+	if postgresDatabase == "" {
+		postgresDatabase = "test"
+	}
+
+	postgresUser := os.Getenv("POSTGRES_USER")
+	if postgresUser == "" {
+		postgresUser = "postgres"
+	}
+	postgresPassword := os.Getenv("POSTGRES_PASSWORD")
+	if postgresPassword == "" {
+		postgresPassword = "postgres#"
+	}
+
+	if postgresUri == "" && postgresHost == "" {
+		panic("Connection params not set")
+	}
+
+	dbConfig := cconf.NewConfigParamsFromTuples(
+		"connection.uri", postgresUri,
+		"connection.host", postgresHost,
+		"connection.port", postgresPort,
+		"connection.database", postgresDatabase,
+		"credential.username", postgresUser,
+		"credential.password", postgresPassword,
+	)
+
+	t.Run("DummyIdPostgresPersistence:ClientIds", func(t *testing.T) {
+		formats := map[string]*regexp.Regexp{
+			"uuid4": regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`),
+			"uuid7": regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`),
+			"ulid":  regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`),
+		}
+		for strategy, format := range formats {
+			persistence := NewDummyPostgresPersistence()
+			persistence.Configure(dbConfig.Override(cconf.NewConfigParamsFromTuples(
+				"table", "dummies_"+strategy,
+				"options.id_strategy", strategy,
+			)))
+			err := persistence.Open("")
+			if err != nil {
+				t.Error("Error opened persistence", err)
+				return
+			}
+
+			dummy, err := persistence.Create("", tf.Dummy{Key: "Key 1", Content: "Content 1"})
+			assert.Nil(t, err)
+			assert.Regexp(t, format, dummy.Id)
+
+			rows, err := persistence.Query(context.Background(), "", "DROP TABLE "+persistence.QuotedTableName())
+			assert.Nil(t, err)
+			rows.Close()
+			persistence.Close("")
+		}
+	})
+
+	t.Run("DummyIdPostgresPersistence:IdColumn", func(t *testing.T) {
+		persistence := NewDummyPostgresPersistence()
+		persistence.Configure(dbConfig.Override(cconf.NewConfigParamsFromTuples(
+			"table", "dummies_key_id",
+			"options.id_column", "key",
+		)))
+		err := persistence.Open("")
+		if err != nil {
+			t.Error("Error opened persistence", err)
+			return
+		}
+		defer persistence.Close("")
+
+		_, err = persistence.Create("", tf.Dummy{Id: "1", Key: "Key 1", Content: "Content 1"})
+		assert.Nil(t, err)
+		_, err = persistence.Create("", tf.Dummy{Id: "2", Key: "Key 2", Content: "Content 2"})
+		assert.Nil(t, err)
+
+		// Items are updated by the id column, not by the Id field
+		result, err := persistence.Update("", tf.Dummy{Id: "3", Key: "Key 1", Content: "Updated Content 1"})
+		assert.Nil(t, err)
+		assert.Equal(t, "Updated Content 1", result.Content)
+
+		item, err := persistence.GetOneById("", "Key 2")
+		assert.Nil(t, err)
+		assert.Equal(t, "Content 2", item.Content)
+
+		_, err = persistence.UpdateIf("", tf.Dummy{Id: "4", Key: "Key 2", Content: "Updated Content 2"},
+			"\"content\"=$1", "Content 2")
+		assert.Nil(t, err)
+		item, err = persistence.GetOneById("", "Key 2")
+		assert.Nil(t, err)
+		assert.Equal(t, "Updated Content 2", item.Content)
+
+		rows, err := persistence.Query(context.Background(), "", "DROP TABLE "+persistence.QuotedTableName())
+		assert.Nil(t, err)
+		rows.Close()
+	})
+
+	t.Run("DummyIdPostgresPersistence:IdentityIds", func(t *testing.T) {
+		persistence := NewDummyNumIdPostgresPersistence()
+		persistence.Configure(dbConfig)
+		err := persistence.Open("")
+		if err != nil {
+			t.Error("Error opened persistence", err)
+			return
+		}
+		defer persistence.Close("")
+		defer persistence.Clear("")
+
+		result, err := persistence.Create("", DummyNumId{Key: "Key 1", Content: "Content 1"})
+		assert.Nil(t, err)
+		dummy1 := result.(DummyNumId)
+		assert.NotZero(t, dummy1.Id)
+
+		result, err = persistence.Create("", DummyNumId{Key: "Key 2", Content: "Content 2"})
+		assert.Nil(t, err)
+		dummy2 := result.(DummyNumId)
+		assert.Greater(t, dummy2.Id, dummy1.Id)
+
+		result, err = persistence.GetOneById("", dummy1.Id)
+		assert.Nil(t, err)
+		assert.Equal(t, dummy1, result)
+
+		dummy1.Content = "Updated Content 1"
+		result, err = persistence.Update("", dummy1)
+		assert.Nil(t, err)
+		assert.Equal(t, dummy1, result)
+
+		result, err = persistence.UpdatePartially("", dummy2.Id,
+			cdata.NewAnyValueMapFromTuples("content", "Updated Content 2"))
+		assert.Nil(t, err)
+		assert.Equal(t, "Updated Content 2", result.(DummyNumId).Content)
+
+		result, err = persistence.Set("", DummyNumId{Key: "Key 3"})
+		assert.Nil(t, err)
+		assert.Greater(t, result.(DummyNumId).Id, dummy2.Id)
+
+		items, err := persistence.GetListByIds("", []interface{}{dummy1.Id, dummy2.Id})
+		assert.Nil(t, err)
+		assert.Len(t, items, 2)
+
+		result, err = persistence.DeleteById("", dummy1.Id)
+		assert.Nil(t, err)
+		assert.Equal(t, dummy1.Id, result.(DummyNumId).Id)
+	})
+
+	t.Run("DummyIdPostgresPersistence:RandomUuidIds", func(t *testing.T) {
+		persistence := NewDummyUuidIdPostgresPersistence()
+		persistence.Configure(dbConfig)
+		err := persistence.Open("")
+		if err != nil {
+			t.Error("Error opened persistence", err)
+			return
+		}
+		defer persistence.Close("")
+		defer persistence.Clear("")
+
+		result, err := persistence.Create("", DummyUuidId{Key: "Key 1", Content: "Content 1"})
+		assert.Nil(t, err)
+		dummy := result.(DummyUuidId)
+		assert.Len(t, dummy.Id, 36)
+
+		result, err = persistence.GetOneById("", dummy.Id)
+		assert.Nil(t, err)
+		assert.Equal(t, dummy, result)
+	})
+
+	t.Run("DummyIdPostgresPersistence:JsonIdentityIds", func(t *testing.T) {
+		persistence := NewDummyJsonNumIdPostgresPersistence()
+		persistence.Configure(dbConfig)
+		err := persistence.Open("")
+		if err != nil {
+			t.Error("Error opened persistence", err)
+			return
+		}
+		defer persistence.Close("")
+		defer persistence.Clear("")
+
+		result, err := persistence.Create("", DummyNumId{Key: "Key 1", Content: "Content 1"})
+		assert.Nil(t, err)
+		dummy := result.(DummyNumId)
+		assert.NotZero(t, dummy.Id)
+
+		// The generated id is stored in data by the same insert
+		rows, err := persistence.Query(context.Background(), "",
+			"SELECT \"data\"->>'num' FROM "+persistence.QuotedTableName()+" WHERE \"id\"=$1", dummy.Id)
+		assert.Nil(t, err)
+		var dataId string
+		assert.True(t, rows.Next())
+		assert.Nil(t, rows.Scan(&dataId))
+		rows.Close()
+		assert.Equal(t, strconv.FormatInt(dummy.Id, 10), dataId)
+
+		result, err = persistence.GetOneById("", dummy.Id)
+		assert.Nil(t, err)
+		assert.Equal(t, dummy, result)
+	})
+}
@@ -127,6 +127,50 @@ func (c *DummyJsonPostgresPersistence) Set(correlationId string, item tf.Dummy)
 	return result, err
 }
 
+func (c *DummyJsonPostgresPersistence) UpsertByKey(correlationId string, item tf.Dummy) (result tf.Dummy, err error) {
+	value, err := c.IdentifiablePostgresPersistence.Upsert(correlationId, item, []string{"(data->'key')"}, nil)
+	if value != nil {
+		val, _ := value.(tf.Dummy)
+		result = val
+	}
+	return result, err
+}
+
+func (c *DummyJsonPostgresPersistence) CreateIfNotExists(correlationId string, item tf.Dummy) (result tf.Dummy, created bool, err error) {
+	value, created, err := c.IdentifiablePostgresPersistence.CreateIfNotExists(correlationId, item, nil)
+	if value != nil {
+		val, _ := value.(tf.Dummy)
+		result = val
+	}
+	return result, created, err
+}
+
+func (c *DummyJsonPostgresPersistence) UpdateByKeys(correlationId string, keys []string, data *cdata.AnyValueMap) (count int64, err error) {
+	return c.IdentifiableJsonPostgresPersistence.UpdateByFilter(correlationId, `data->>'key'=ANY($1)`, data, keys)
+}
+
+func (c *DummyJsonPostgresPersistence) UpdateByKeysReturning(correlationId string, keys []string, data *cdata.AnyValueMap) (items []tf.Dummy, err error) {
+	result, err := c.IdentifiableJsonPostgresPersistence.UpdateByFilterReturning(correlationId, `data->>'key'=ANY($1)`, data, keys)
+	items = make([]tf.Dummy, len(result))
+	for i, v := range result {
+		items[i], _ = v.(tf.Dummy)
+	}
+	return items, err
+}
+
+func (c *DummyJsonPostgresPersistence) DeleteByKeys(correlationId string, keys []string) (count int64, err error) {
+	return c.IdentifiableJsonPostgresPersistence.DeleteByFilter(correlationId, `data->>'key'=ANY($1)`, keys)
+}
+
+func (c *DummyJsonPostgresPersistence) DeleteByKeysReturning(correlationId string, keys []string) (items []tf.Dummy, err error) {
+	result, err := c.IdentifiableJsonPostgresPersistence.DeleteByFilterReturning(correlationId, `data->>'key'=ANY($1)`, keys)
+	items = make([]tf.Dummy, len(result))
+	for i, v := range result {
+		items[i], _ = v.(tf.Dummy)
+	}
+	return items, err
+}
+
 func (c *DummyJsonPostgresPersistence) UpdatePartially(correlationId string, id string, data *cdata.AnyValueMap) (item tf.Dummy, err error) {
 	// In json persistence this method must call from IdentifiableJsonPostgresPersistence
 	result, err := c.IdentifiableJsonPostgresPersistence.UpdatePartially(correlationId, id, data)
@@ -152,5 +196,6 @@ func (c *DummyJsonPostgresPersistence) DeleteByIds(correlationId string, ids []s
 	for i, v := range ids {
 		convIds[i] = v
 	}
-	return c.IdentifiablePostgresPersistence.DeleteByIds(correlationId, convIds)
+	_, err = c.IdentifiablePostgresPersistence.DeleteByIds(correlationId, convIds)
+	return err
 }
@@ -1,11 +1,16 @@
 package test
 
 import (
+	"context"
 	"os"
 	"testing"
 
 	cconf "github.com/pip-services3-go/pip-services3-commons-go/config"
+	cdata "github.com/pip-services3-go/pip-services3-commons-go/data"
+	cerr "github.com/pip-services3-go/pip-services3-commons-go/errors"
+	persist "github.com/pip-services3-go/pip-services3-postgres-go/persistence"
 	tf "github.com/pip-services3-go/pip-services3-postgres-go/test/fixtures"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestDummyJsonPostgresPersistence(t *testing.T) {
@@ -78,4 +83,107 @@ func TestDummyJsonPostgresPersistence(t *testing.T) {
 
 	t.Run("DummyPostgresConnection:Batch", fixture.TestBatchOperations)
 
+	opnErr = persistence.Clear("")
+	if opnErr != nil {
+		t.Error("Error cleaned persistence", opnErr)
+		return
+	}
+
+	t.Run("DummyJsonPostgresPersistence:Upsert", tf.NewDummyUpsertPersistenceFixture(persistence).TestUpsertOperations)
+
+	opnErr = persistence.Clear("")
+	if opnErr != nil {
+		t.Error("Error cleaned persistence", opnErr)
+		return
+	}
+
+	t.Run("DummyJsonPostgresPersistence:Bulk", tf.NewDummyBulkPersistenceFixture(persistence).TestBulkOperations)
+
+	// Fields inside the data column are changed atomically
+	t.Run("DummyJsonPostgresPersistence:Operations", func(t *testing.T) {
+		dummy, err := persistence.Create("", tf.Dummy{Key: "Key ops", Content: "Content ops"})
+		assert.Nil(t, err)
+
+		operations := persist.NewPostgresUpdateOperations().
+			SetPath("data", []string{"content"}, "Updated Content ops")
+		result, err := persistence.UpdateByOperations("", dummy.Id, operations)
+		assert.Nil(t, err)
+		assert.Equal(t, tf.Dummy{Id: dummy.Id, Key: "Key ops", Content: "Updated Content ops"}, result)
+
+		operations = persist.NewPostgresUpdateOperations().
+			UnsetPath("data", []string{"content"})
+		result, err = persistence.UpdateByOperations("", dummy.Id, operations)
+		assert.Nil(t, err)
+		assert.Equal(t, tf.Dummy{Id: dummy.Id, Key: "Key ops"}, result)
+	})
+
+	// Operations without a path change the data column as JSON
+	t.Run("DummyJsonPostgresPersistence:DataOperations", func(t *testing.T) {
+		dummy, err := persistence.Create("", tf.Dummy{Key: "Key data ops", Content: "Content data ops"})
+		assert.Nil(t, err)
+
+		_, err = persistence.UpdateByOperations("", dummy.Id,
+			persist.NewPostgresUpdateOperations().Append("data", "item"))
+		assert.Nil(t, err)
+
+		rows, err := persistence.Query(context.Background(), "",
+			"SELECT jsonb_typeof(\"data\"), \"data\"->>-1 FROM "+persistence.QuotedTableName()+" WHERE \"id\"=$1", dummy.Id)
+		assert.Nil(t, err)
+		var dataType, last string
+		assert.True(t, rows.Next())
+		assert.Nil(t, rows.Scan(&dataType, &last))
+		rows.Close()
+		assert.Equal(t, "array", dataType)
+		assert.Equal(t, "item", last)
+
+		_, err = persistence.UpdateByOperations("", dummy.Id,
+			persist.NewPostgresUpdateOperations().Remove("data", "item"))
+		assert.Nil(t, err)
+
+		rows, err = persistence.Query(context.Background(), "",
+			"SELECT jsonb_array_length(\"data\") FROM "+persistence.QuotedTableName()+" WHERE \"id\"=$1", dummy.Id)
+		assert.Nil(t, err)
+		var length int
+		assert.True(t, rows.Next())
+		assert.Nil(t, rows.Scan(&length))
+		rows.Close()
+		assert.Equal(t, 1, length)
+
+		_, err = persistence.DeleteById("", dummy.Id)
+		assert.Nil(t, err)
+	})
+
+	// Data of the row updated on conflict keeps the id of the row
+	t.Run("DummyJsonPostgresPersistence:UpsertDataId", func(t *testing.T) {
+		dummy, err := persistence.Create("", tf.Dummy{Key: "Key upsert", Content: "Content 1"})
+		assert.Nil(t, err)
+
+		result, err := persistence.UpsertByKey("", tf.Dummy{Id: "other", Key: "Key upsert", Content: "Content 2"})
+		assert.Nil(t, err)
+		assert.Equal(t, tf.Dummy{Id: dummy.Id, Key: "Key upsert", Content: "Content 2"}, result)
+
+		rows, err := persistence.Query(context.Background(), "",
+			"SELECT \"data\"->>'id' FROM "+persistence.QuotedTableName()+" WHERE \"id\"=$1", dummy.Id)
+		assert.Nil(t, err)
+		var dataId string
+		assert.True(t, rows.Next())
+		assert.Nil(t, rows.Scan(&dataId))
+		rows.Close()
+		assert.Equal(t, dummy.Id, dataId)
+	})
+
+	t.Run("DummyJsonPostgresPersistence:UpdateIf", func(t *testing.T) {
+		dummy, err := persistence.Create("", tf.Dummy{Key: "Key if", Content: "pending"})
+		assert.Nil(t, err)
+
+		result, err := persistence.UpdatePartiallyIf("", dummy.Id,
+			cdata.NewAnyValueMapFromTuples("content", "done"), "data->>'content'=$1", "pending")
+		assert.Nil(t, err)
+		assert.Equal(t, tf.Dummy{Id: dummy.Id, Key: "Key if", Content: "done"}, result)
+
+		result, err = persistence.UpdatePartiallyIf("", dummy.Id,
+			cdata.NewAnyValueMapFromTuples("content", "canceled"), "data->>'content'=$1", "pending")
+		assert.Nil(t, result)
+		assert.Equal(t, "CONDITION_FAILED", err.(*cerr.ApplicationError).Code)
+	})
 }
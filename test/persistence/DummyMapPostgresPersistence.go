@@ -105,7 +105,8 @@ func (c *DummyMapPostgresPersistence) DeleteByIds(correlationId string, ids []st
 	for i, v := range ids {
 		convIds[i] = v
 	}
-	return c.IdentifiablePostgresPersistence.DeleteByIds(correlationId, convIds)
+	_, err = c.IdentifiablePostgresPersistence.DeleteByIds(correlationId, convIds)
+	return err
 }
 
 func (c *DummyMapPostgresPersistence) GetPageByFilter(correlationId string, filter *cdata.FilterParams, paging *cdata.PagingParams) (page *tf.MapPage, err error) {
@@ -89,6 +89,8 @@ func TestDummyPostgresConnection(t *testing.T) {
 		assert.NotNil(t, connection.GetConnection())
 		assert.NotNil(t, connection.GetDatabaseName())
 		assert.NotEqual(t, "", connection.GetDatabaseName())
+		// Opened by the test and by the persistence
+		assert.Equal(t, 2, connection.GetUsersCount())
 	})
 
 	t.Run("DummyPostgresConnection:CRUD", fixture.TestCrudOperations)
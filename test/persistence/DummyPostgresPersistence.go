@@ -87,6 +87,50 @@ func (c *DummyPostgresPersistence) Set(correlationId string, item tf.Dummy) (res
 	return result, err
 }
 
+func (c *DummyPostgresPersistence) UpsertByKey(correlationId string, item tf.Dummy) (result tf.Dummy, err error) {
+	value, err := c.IdentifiablePostgresPersistence.Upsert(correlationId, item, []string{"key"}, nil)
+	if value != nil {
+		val, _ := value.(tf.Dummy)
+		result = val
+	}
+	return result, err
+}
+
+func (c *DummyPostgresPersistence) CreateIfNotExists(correlationId string, item tf.Dummy) (result tf.Dummy, created bool, err error) {
+	value, created, err := c.IdentifiablePostgresPersistence.CreateIfNotExists(correlationId, item, nil)
+	if value != nil {
+		val, _ := value.(tf.Dummy)
+		result = val
+	}
+	return result, created, err
+}
+
+func (c *DummyPostgresPersistence) UpdateByKeys(correlationId string, keys []string, data *cdata.AnyValueMap) (count int64, err error) {
+	return c.IdentifiablePostgresPersistence.UpdateByFilter(correlationId, `"key"=ANY($1)`, data, keys)
+}
+
+func (c *DummyPostgresPersistence) UpdateByKeysReturning(correlationId string, keys []string, data *cdata.AnyValueMap) (items []tf.Dummy, err error) {
+	result, err := c.IdentifiablePostgresPersistence.UpdateByFilterReturning(correlationId, `"key"=ANY($1)`, data, keys)
+	items = make([]tf.Dummy, len(result))
+	for i, v := range result {
+		items[i], _ = v.(tf.Dummy)
+	}
+	return items, err
+}
+
+func (c *DummyPostgresPersistence) DeleteByKeys(correlationId string, keys []string) (count int64, err error) {
+	return c.IdentifiablePostgresPersistence.DeleteByFilter(correlationId, `"key"=ANY($1)`, keys)
+}
+
+func (c *DummyPostgresPersistence) DeleteByKeysReturning(correlationId string, keys []string) (items []tf.Dummy, err error) {
+	result, err := c.IdentifiablePostgresPersistence.DeleteByFilterReturning(correlationId, `"key"=ANY($1)`, keys)
+	items = make([]tf.Dummy, len(result))
+	for i, v := range result {
+		items[i], _ = v.(tf.Dummy)
+	}
+	return items, err
+}
+
 func (c *DummyPostgresPersistence) UpdatePartially(correlationId string, id string, data *cdata.AnyValueMap) (item tf.Dummy, err error) {
 	result, err := c.IdentifiablePostgresPersistence.UpdatePartially(correlationId, id, data)
 
@@ -111,7 +155,8 @@ func (c *DummyPostgresPersistence) DeleteByIds(correlationId string, ids []strin
 	for i, v := range ids {
 		convIds[i] = v
 	}
-	return c.IdentifiablePostgresPersistence.DeleteByIds(correlationId, convIds)
+	_, err = c.IdentifiablePostgresPersistence.DeleteByIds(correlationId, convIds)
+	return err
 }
 
 func (c *DummyPostgresPersistence) GetPageByFilter(correlationId string, filter *cdata.FilterParams, paging *cdata.PagingParams) (page *tf.DummyPage, err error) {
@@ -1,11 +1,16 @@
 package test
 
 import (
+	"context"
 	"os"
+	"sync"
 	"testing"
 
 	cconf "github.com/pip-services3-go/pip-services3-commons-go/config"
+	cdata "github.com/pip-services3-go/pip-services3-commons-go/data"
+	cerr "github.com/pip-services3-go/pip-services3-commons-go/errors"
 	tf "github.com/pip-services3-go/pip-services3-postgres-go/test/fixtures"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestDummyPostgresPersistence(t *testing.T) {
@@ -86,4 +91,150 @@ func TestDummyPostgresPersistence(t *testing.T) {
 	}
 
 	t.Run("DummyPostgresPersistence:Random", fixture.TestRandomOperation)
+
+	opnErr = persistence.Clear("")
+	if opnErr != nil {
+		t.Error("Error cleaned persistence", opnErr)
+		return
+	}
+
+	t.Run("DummyPostgresPersistence:Upsert", tf.NewDummyUpsertPersistenceFixture(persistence).TestUpsertOperations)
+
+	opnErr = persistence.Clear("")
+	if opnErr != nil {
+		t.Error("Error cleaned persistence", opnErr)
+		return
+	}
+
+	t.Run("DummyPostgresPersistence:Bulk", tf.NewDummyBulkPersistenceFixture(persistence).TestBulkOperations)
+
+	// Only selected columns are updated on conflict
+	t.Run("DummyPostgresPersistence:UpsertColumns", func(t *testing.T) {
+		dummy, err := persistence.Create("", tf.Dummy{Key: "Key 5", Content: "Content 5"})
+		assert.Nil(t, err)
+
+		result, err := persistence.IdentifiablePostgresPersistence.Upsert("",
+			tf.Dummy{Id: dummy.Id, Key: "Key 6", Content: "Upserted Content 5"}, nil, []string{"content"})
+		assert.Nil(t, err)
+		assert.Equal(t, tf.Dummy{Id: dummy.Id, Key: "Key 5", Content: "Upserted Content 5"}, result)
+
+		// Nothing to update
+		result, err = persistence.IdentifiablePostgresPersistence.Upsert("",
+			tf.Dummy{Id: dummy.Id, Key: "Key 6"}, nil, []string{})
+		assert.Nil(t, err)
+		assert.Nil(t, result)
+	})
+
+	// Updates apply only when the stored item matches the condition
+	t.Run("DummyPostgresPersistence:UpdateIf", func(t *testing.T) {
+		dummy, err := persistence.Create("", tf.Dummy{Key: "Key if", Content: "pending"})
+		assert.Nil(t, err)
+
+		dummy.Content = "done"
+		result, err := persistence.IdentifiablePostgresPersistence.UpdateIf("", dummy, "\"content\"=$1", "pending")
+		assert.Nil(t, err)
+		assert.Equal(t, dummy, result)
+
+		// The item is not pending anymore
+		result, err = persistence.IdentifiablePostgresPersistence.UpdatePartiallyIf("", dummy.Id,
+			cdata.NewAnyValueMapFromTuples("content", "canceled"), "\"content\"=$1", "pending")
+		assert.Nil(t, result)
+		assert.IsType(t, &cerr.ApplicationError{}, err)
+		assert.Equal(t, "CONDITION_FAILED", err.(*cerr.ApplicationError).Code)
+
+		result, err = persistence.IdentifiablePostgresPersistence.UpdatePartiallyIf("", "missing",
+			cdata.NewAnyValueMapFromTuples("content", "canceled"), "\"content\"=$1", "pending")
+		assert.Nil(t, result)
+		assert.IsType(t, &cerr.ApplicationError{}, err)
+		assert.Equal(t, "NOT_FOUND", err.(*cerr.ApplicationError).Code)
+	})
+
+	// Several instances create the same table at once
+	t.Run("DummyPostgresPersistence:ConcurrentOpen", func(t *testing.T) {
+		config := dbConfig.Override(cconf.NewConfigParamsFromTuples("table", "dummies_concurrent"))
+
+		persistences := make([]*DummyPostgresPersistence, 5)
+		errs := make([]error, len(persistences))
+		wg := sync.WaitGroup{}
+		for i := range persistences {
+			persistences[i] = NewDummyPostgresPersistence()
+			persistences[i].Configure(config)
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = persistences[i].Open("")
+			}(i)
+		}
+		wg.Wait()
+
+		for i := range persistences {
+			assert.Nil(t, errs[i])
+		}
+
+		rows, err := persistences[0].Query(context.Background(), "", "DROP TABLE "+persistences[0].QuotedTableName())
+		assert.Nil(t, err)
+		if rows != nil {
+			rows.Close()
+		}
+		for i := range persistences {
+			persistences[i].Close("")
+		}
+	})
+
+	// Table differs from declared columns and indexes
+	t.Run("DummyPostgresPersistence:SchemaDrift", func(t *testing.T) {
+		config := dbConfig.Override(cconf.NewConfigParamsFromTuples("table", "dummies_drift"))
+
+		drifted := NewDummyPostgresPersistence()
+		drifted.Configure(config)
+		err := drifted.Open("")
+		assert.Nil(t, err)
+
+		drift, err := drifted.VerifySchema("")
+		assert.Nil(t, err)
+		assert.False(t, drift.IsBreaking())
+
+		// INCLUDE columns and expressions with commas are not taken for keys
+		for _, query := range []string{
+			"DROP INDEX \"dummies_drift_key\"",
+			"CREATE UNIQUE INDEX \"dummies_drift_key\" ON " + drifted.QuotedTableName() + " (\"key\") INCLUDE (\"content\")",
+			"CREATE INDEX \"dummies_drift_expr\" ON " + drifted.QuotedTableName() + " (COALESCE(\"key\", \"content\"))",
+		} {
+			rows, err := drifted.Query(context.Background(), "", query)
+			assert.Nil(t, err)
+			rows.Close()
+		}
+
+		drift, err = drifted.VerifySchema("")
+		assert.Nil(t, err)
+		assert.Len(t, drift.MismatchedIndexes, 0)
+		assert.Equal(t, []string{"dummies_drift_expr"}, drift.ExtraIndexes)
+
+		rows, err := drifted.Query(context.Background(), "",
+			"ALTER TABLE "+drifted.QuotedTableName()+" DROP COLUMN \"content\"")
+		assert.Nil(t, err)
+		rows.Close()
+		drifted.Close("")
+
+		// Fail on missing column
+		drifted = NewDummyPostgresPersistence()
+		drifted.Configure(config.Override(cconf.NewConfigParamsFromTuples("options.schema_drift", "fail")))
+		err = drifted.Open("")
+		assert.NotNil(t, err)
+
+		// Add missing column
+		drifted = NewDummyPostgresPersistence()
+		drifted.Configure(config.Override(cconf.NewConfigParamsFromTuples("options.schema_drift", "apply")))
+		err = drifted.Open("")
+		assert.Nil(t, err)
+
+		drift, err = drifted.VerifySchema("")
+		assert.Nil(t, err)
+		assert.Len(t, drift.MissingColumns, 0)
+
+		rows, err = drifted.Query(context.Background(), "", "DROP TABLE "+drifted.QuotedTableName())
+		assert.Nil(t, err)
+		rows.Close()
+		drifted.Close("")
+	})
 }
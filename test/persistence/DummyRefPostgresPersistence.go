@@ -96,7 +96,8 @@ func (c *DummyRefPostgresPersistence) DeleteByIds(correlationId string, ids []st
 	for i, v := range ids {
 		convIds[i] = v
 	}
-	return c.IdentifiablePostgresPersistence.DeleteByIds(correlationId, convIds)
+	_, err = c.IdentifiablePostgresPersistence.DeleteByIds(correlationId, convIds)
+	return err
 }
 
 func (c *DummyRefPostgresPersistence) GetPageByFilter(correlationId string, filter *cdata.FilterParams, paging *cdata.PagingParams) (page *tf.DummyRefPage, err error) {
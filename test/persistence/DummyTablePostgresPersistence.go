@@ -0,0 +1,47 @@
+package test
+
+import (
+	"reflect"
+	"time"
+
+	persist "github.com/pip-services3-go/pip-services3-postgres-go/persistence"
+)
+
+type DummyTable struct {
+	Id       string                 `json:"id"`
+	Key      string                 `json:"key" postgres:"type:VARCHAR(50);notnull;unique"`
+	Content  string                 `json:"content"`
+	Count    int64                  `json:"count" postgres:"default:0;index"`
+	Created  time.Time              `json:"created" postgres:"default:now()"`
+	Tags     []string               `json:"tags"`
+	Params   map[string]interface{} `json:"params"`
+	Internal string                 `json:"-"`
+}
+
+type DummyReport struct {
+	Color   string    `json:"color"`
+	Day     time.Time `json:"day"`
+	Items   int64     `json:"items"`
+	Total   float64   `json:"total"`
+	Average float64   `json:"average"`
+	Max     int64     `json:"max"`
+	Median  float64   `json:"median"`
+	Score   float64   `json:"score"`
+}
+
+type DummyTablePostgresPersistence struct {
+	persist.IdentifiablePostgresPersistence
+}
+
+func NewDummyTablePostgresPersistence() *DummyTablePostgresPersistence {
+	proto := reflect.TypeOf(DummyTable{})
+	c := &DummyTablePostgresPersistence{}
+	c.IdentifiablePostgresPersistence = *persist.InheritIdentifiablePostgresPersistence(c, proto, "dummies_table")
+	return c
+}
+
+func (c *DummyTablePostgresPersistence) DefineSchema() {
+	c.ClearSchema()
+	c.IdentifiablePostgresPersistence.DefineSchema()
+	c.EnsureTableFromPrototype()
+}
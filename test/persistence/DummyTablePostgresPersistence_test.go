@@ -0,0 +1,382 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgproto3/v2"
+	cconf "github.com/pip-services3-go/pip-services3-commons-go/config"
+	cerr "github.com/pip-services3-go/pip-services3-commons-go/errors"
+	persist "github.com/pip-services3-go/pip-services3-postgres-go/persistence"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDummyTablePostgresPersistence(t *testing.T) {
+
+	postgresUri := os.Getenv("POSTGRES_URI")
+	postgresHost := os.Getenv("POSTGRES_HOST")
+	if postgresHost == "" {
+		postgresHost = "localhost"
+	}
+
+	postgresPort := os.Getenv("POSTGRES_PORT")
+	if postgresPort == "" {
+		postgresPort = "5432"
+	}
+
+	postgresDatabase := os.Getenv("POSTGRES_DB")
+	if postgresDatabase == "" {
+		postgresDatabase = "test"
+	}
+
+	postgresUser := os.Getenv("POSTGRES_USER")
+	if postgresUser == "" {
+		postgresUser = "postgres"
+	}
+	postgresPassword := os.Getenv("POSTGRES_PASSWORD")
+	if postgresPassword == "" {
+		postgresPassword = "postgres#"
+	}
+
+	if postgresUri == "" && postgresHost == "" {
+		panic("Connection params not set")
+	}
+
+	dbConfig := cconf.NewConfigParamsFromTuples(
+		"connection.uri", postgresUri,
+		"connection.host", postgresHost,
+		"connection.port", postgresPort,
+		"connection.database", postgresDatabase,
+		"credential.username", postgresUser,
+		"credential.password", postgresPassword,
+		"options.schema_drift", "fail",
+	)
+
+	persistence := NewDummyTablePostgresPersistence()
+	persistence.Configure(dbConfig)
+
+	opnErr := persistence.Open("")
+	if opnErr != nil {
+		t.Error("Error opened persistence", opnErr)
+		return
+	}
+	defer persistence.Close("")
+
+	t.Run("DummyTablePostgresPersistence:Columns", func(t *testing.T) {
+		rows, err := persistence.Query(context.Background(), "",
+			"SELECT column_name, data_type, is_nullable FROM information_schema.columns WHERE table_name=$1",
+			persistence.TableName)
+		assert.Nil(t, err)
+		defer rows.Close()
+
+		types := make(map[string]string)
+		nullable := make(map[string]string)
+		for rows.Next() {
+			var name, dataType, isNullable string
+			err = rows.Scan(&name, &dataType, &isNullable)
+			assert.Nil(t, err)
+			types[name] = dataType
+			nullable[name] = isNullable
+		}
+
+		assert.Len(t, types, 7)
+		assert.Equal(t, "text", types["id"])
+		assert.Equal(t, "character varying", types["key"])
+		assert.Equal(t, "NO", nullable["key"])
+		assert.Equal(t, "bigint", types["count"])
+		assert.Equal(t, "timestamp with time zone", types["created"])
+		assert.Equal(t, "jsonb", types["tags"])
+		assert.Equal(t, "jsonb", types["params"])
+	})
+
+	t.Run("DummyTablePostgresPersistence:Drift", func(t *testing.T) {
+		drift, err := persistence.VerifySchema("")
+		assert.Nil(t, err)
+		assert.False(t, drift.IsBreaking())
+		// Index of UNIQUE constraint on the key column is not extra
+		assert.Empty(t, drift.ExtraIndexes)
+	})
+
+	t.Run("DummyTablePostgresPersistence:Operations", func(t *testing.T) {
+		result, err := persistence.Create("", DummyTable{Id: "ops", Key: "Key ops", Count: 5,
+			Tags: []string{"a", "b"}, Params: map[string]interface{}{"x": 1}})
+		assert.Nil(t, err)
+		assert.NotNil(t, result)
+
+		operations := persist.NewPostgresUpdateOperations().
+			Increment("count", 3).
+			Decrement("count", 1).
+			Append("tags", "c").
+			Remove("tags", "a").
+			IncrementPath("params", []string{"x"}, 2).
+			SetPath("params", []string{"options", "color"}, "red").
+			AppendPath("params", []string{"list"}, "item").
+			Unset("content")
+		result, err = persistence.UpdateByOperations("", "ops", operations)
+		assert.Nil(t, err)
+		dummy := result.(DummyTable)
+		assert.Equal(t, int64(7), dummy.Count)
+		assert.Equal(t, []string{"b", "c"}, dummy.Tags)
+		assert.Equal(t, float64(3), dummy.Params["x"])
+		assert.Equal(t, map[string]interface{}{"color": "red"}, dummy.Params["options"])
+		assert.Equal(t, []interface{}{"item"}, dummy.Params["list"])
+
+		operations = persist.NewPostgresUpdateOperations().
+			Max("count", 5).
+			Min("count", 6).
+			MaxPath("params", []string{"x"}, 10).
+			UnsetPath("params", []string{"options"})
+		result, err = persistence.UpdateByOperations("", "ops", operations)
+		assert.Nil(t, err)
+		dummy = result.(DummyTable)
+		assert.Equal(t, int64(6), dummy.Count)
+		assert.Equal(t, float64(10), dummy.Params["x"])
+		assert.NotContains(t, dummy.Params, "options")
+
+		// Chained operations on the same path
+		operations = persist.NewPostgresUpdateOperations()
+		for index := 0; index < 5; index++ {
+			operations.IncrementPath("params", []string{"stats", "views", "total"}, 1)
+		}
+		result, err = persistence.UpdateByOperations("", "ops", operations)
+		assert.Nil(t, err)
+		dummy = result.(DummyTable)
+		assert.Equal(t, map[string]interface{}{"views": map[string]interface{}{"total": float64(5)}}, dummy.Params["stats"])
+
+		// Missing item
+		result, err = persistence.UpdateByOperations("", "missing", operations)
+		assert.Nil(t, err)
+		assert.Nil(t, result)
+	})
+
+	t.Run("DummyTablePostgresPersistence:Locking", func(t *testing.T) {
+		for _, id := range []string{"job1", "job2", "job3"} {
+			_, err := persistence.Create("", DummyTable{Id: id, Key: "Key " + id, Content: "job"})
+			assert.Nil(t, err)
+		}
+
+		tx1, err := persistence.BeginTransaction("")
+		assert.Nil(t, err)
+		defer tx1.Rollback(context.Background())
+		tx2, err := persistence.BeginTransaction("")
+		assert.Nil(t, err)
+		defer tx2.Rollback(context.Background())
+
+		// Workers claim different rows
+		claim := &persist.PostgresLockOptions{Strength: persist.LockForUpdate, Wait: persist.LockSkipLocked}
+		items1, err := persistence.GetListByFilterLocked("", tx1, "content=$1", "id", 2, claim, "job")
+		assert.Nil(t, err)
+		assert.Len(t, items1, 2)
+		assert.Equal(t, "job1", items1[0].(DummyTable).Id)
+		items2, err := persistence.GetListByFilterLocked("", tx2, "content=$1", "id", 2, claim, "job")
+		assert.Nil(t, err)
+		assert.Len(t, items2, 1)
+		assert.Equal(t, "job3", items2[0].(DummyTable).Id)
+
+		// Locked row is skipped
+		item, err := persistence.GetOneByIdLocked("", tx2, "job1", claim)
+		assert.Nil(t, err)
+		assert.Nil(t, item)
+
+		// Locked row fails without waiting
+		sp, err := tx2.Begin(context.Background())
+		assert.Nil(t, err)
+		noWait := &persist.PostgresLockOptions{Strength: persist.LockForNoKeyUpdate, Wait: persist.LockNoWait}
+		_, err = persistence.GetListByIdsLocked("", sp, []interface{}{"job1", "job3"}, noWait)
+		assert.NotNil(t, err)
+		assert.Equal(t, "LOCK_NOT_AVAILABLE", err.(*cerr.ApplicationError).Code)
+		sp.Rollback(context.Background())
+
+		// Locked row fails after lock timeout
+		sp, err = tx2.Begin(context.Background())
+		assert.Nil(t, err)
+		timeout := &persist.PostgresLockOptions{Strength: persist.LockForShare, Timeout: 100}
+		_, err = persistence.GetOneByIdLocked("", sp, "job2", timeout)
+		assert.NotNil(t, err)
+		assert.Equal(t, "LOCK_NOT_AVAILABLE", err.(*cerr.ApplicationError).Code)
+		sp.Rollback(context.Background())
+
+		// Rows are released when the transaction completes
+		assert.Nil(t, tx1.Commit(context.Background()))
+		item, err = persistence.GetOneByIdLocked("", tx2, "job1", nil)
+		assert.Nil(t, err)
+		assert.NotNil(t, item)
+	})
+
+	t.Run("DummyTablePostgresPersistence:Aggregate", func(t *testing.T) {
+		for index, color := range []string{"red", "red", "blue"} {
+			_, err := persistence.Create("", DummyTable{Id: "agg" + strconv.Itoa(index), Key: "Key agg" + strconv.Itoa(index),
+				Content: "agg", Count: int64(index*2 + 1), Params: map[string]interface{}{"color": color, "score": (index + 1) * 10}})
+			assert.Nil(t, err)
+		}
+
+		// Groups into typed rows with having and sorting by aggregates
+		aggregation := persist.NewPostgresAggregation().
+			GroupByPath("color", "params", []string{"color"}).
+			Count("items").
+			Sum("total", "count").
+			Avg("average", "count").
+			Max("max", "count").
+			Percentile("median", "count", 0.5).
+			SumPath("score", "params", []string{"score"}).
+			Having("items > $2")
+		items, err := persistence.Aggregate("", "content=$1", aggregation, "total DESC",
+			reflect.TypeOf(DummyReport{}), "agg", 1)
+		assert.Nil(t, err)
+		assert.Len(t, items, 1)
+		report := items[0].(DummyReport)
+		assert.Equal(t, "red", report.Color)
+		assert.Equal(t, int64(2), report.Items)
+		assert.Equal(t, float64(4), report.Total)
+		assert.Equal(t, float64(2), report.Average)
+		assert.Equal(t, int64(3), report.Max)
+		assert.Equal(t, float64(2), report.Median)
+		assert.Equal(t, float64(30), report.Score)
+
+		// Time buckets into maps
+		aggregation = persist.NewPostgresAggregation().
+			GroupByTime("day", "created", "day").
+			Count("items").
+			Sum("total", "count")
+		items, err = persistence.Aggregate("", "content=$1", aggregation, "", nil, "agg")
+		assert.Nil(t, err)
+		assert.Len(t, items, 1)
+		row := items[0].(map[string]interface{})
+		assert.Equal(t, int64(3), row["items"])
+		assert.Equal(t, float64(9), row["total"])
+		assert.IsType(t, time.Time{}, row["day"])
+	})
+
+	rows, err := persistence.Query(context.Background(), "", "DROP TABLE "+persistence.QuotedTableName())
+	assert.Nil(t, err)
+	rows.Close()
+}
+
+// Rows with a single row of values for conversion tests
+type testRows struct {
+	names  []string
+	values []interface{}
+}
+
+func (r *testRows) Close()                        {}
+func (r *testRows) Err() error                    { return nil }
+func (r *testRows) CommandTag() pgconn.CommandTag { return nil }
+func (r *testRows) Next() bool                    { return false }
+func (r *testRows) Scan(dest ...interface{}) error {
+	return errors.New("not supported")
+}
+func (r *testRows) Values() ([]interface{}, error) { return r.values, nil }
+func (r *testRows) RawValues() [][]byte            { return nil }
+func (r *testRows) FieldDescriptions() []pgproto3.FieldDescription {
+	fields := make([]pgproto3.FieldDescription, len(r.names))
+	for i, name := range r.names {
+		fields[i] = pgproto3.FieldDescription{Name: []byte(name)}
+	}
+	return fields
+}
+
+func TestDummyTableConversion(t *testing.T) {
+	persistence := NewDummyTablePostgresPersistence()
+	created := time.Date(2021, 5, 1, 10, 30, 0, 0, time.UTC)
+
+	// Columns are assigned to fields keeping their types
+	rows := &testRows{
+		names: []string{"id", "key", "content", "count", "created", "tags", "params", "unknown"},
+		values: []interface{}{"1", "key1", nil, int64(5), created,
+			[]interface{}{"a", "b"}, map[string]interface{}{"x": float64(1)}, "skip"},
+	}
+	item, ok := persistence.ConvertToPublic(rows).(DummyTable)
+	assert.True(t, ok)
+	assert.Equal(t, "1", item.Id)
+	assert.Equal(t, "key1", item.Key)
+	assert.Equal(t, "", item.Content)
+	assert.Equal(t, int64(5), item.Count)
+	assert.True(t, created.Equal(item.Created))
+	assert.Equal(t, []string{"a", "b"}, item.Tags)
+	assert.Equal(t, float64(1), item.Params["x"])
+
+	// Fields are passed as column values without JSON conversion
+	values := DummyTable{Id: "1", Key: "key1", Count: 5, Created: created, Internal: "skip"}
+	columns := persistence.GenerateColumns(values)
+	assert.NotContains(t, columns, "Internal")
+	generated := persistence.GenerateValues(columns, values)
+	assert.Len(t, generated, 7)
+	assert.Contains(t, generated, int64(5))
+	assert.Contains(t, generated, created)
+
+	// JSON conversion fallback
+	persistence.Configure(cconf.NewConfigParamsFromTuples("options.json_conversion", true))
+	item, ok = persistence.ConvertToPublic(rows).(DummyTable)
+	assert.True(t, ok)
+	assert.Equal(t, int64(5), item.Count)
+	generated = persistence.GenerateValues(columns, values)
+	assert.Contains(t, generated, float64(5))
+}
+
+func TestDummyTableOperationsValidation(t *testing.T) {
+	persistence := NewDummyTablePostgresPersistence()
+
+	// Values of JSON paths are checked before queries are sent
+	operations := persist.NewPostgresUpdateOperations().
+		SetPath("params", []string{"x"}, make(chan int))
+	result, err := persistence.UpdateByOperations("", "1", operations)
+	assert.NotNil(t, err)
+	assert.Nil(t, result)
+
+	// Nothing to update
+	result, err = persistence.UpdateByOperations("", "1", persist.NewPostgresUpdateOperations())
+	assert.Nil(t, err)
+	assert.Nil(t, result)
+}
+
+func TestDummyTableLockingValidation(t *testing.T) {
+	persistence := NewDummyTablePostgresPersistence()
+
+	// Locking reads fail outside of a transaction
+	item, err := persistence.GetOneByIdLocked("", nil, "1", nil)
+	assert.NotNil(t, err)
+	assert.Nil(t, item)
+	assert.Equal(t, "NO_TRANSACTION", err.(*cerr.ApplicationError).Code)
+
+	items, err := persistence.GetListByIdsLocked("", nil, []interface{}{"1"}, persist.NewPostgresLockOptions())
+	assert.NotNil(t, err)
+	assert.Nil(t, items)
+
+	items, err = persistence.GetListByFilterLocked("", nil, "", "", 1, nil)
+	assert.NotNil(t, err)
+	assert.Nil(t, items)
+
+	// Transactions need an opened connection
+	tx, err := persistence.BeginTransaction("")
+	assert.NotNil(t, err)
+	assert.Nil(t, tx)
+}
+
+func TestDummyTableAggregateValidation(t *testing.T) {
+	persistence := NewDummyTablePostgresPersistence()
+
+	// Aggregations are checked before queries are sent
+	invalid := map[string]*persist.PostgresAggregation{
+		"NO_AGGREGATES":     persist.NewPostgresAggregation(),
+		"INVALID_ALIAS":     persist.NewPostgresAggregation().Count("items").Sum("items", "count"),
+		"INVALID_TIME_UNIT": persist.NewPostgresAggregation().GroupByTime("day", "created", "days").Count("items"),
+		"INVALID_FRACTION":  persist.NewPostgresAggregation().Percentile("p", "count", 1.5),
+	}
+	for code, aggregation := range invalid {
+		items, err := persistence.Aggregate("", "", aggregation, "", nil)
+		assert.NotNil(t, err)
+		assert.Nil(t, items)
+		assert.Equal(t, code, err.(*cerr.ApplicationError).Code)
+	}
+
+	items, err := persistence.Aggregate("", "", persist.NewPostgresAggregation().Count("items"), "", reflect.TypeOf(0))
+	assert.NotNil(t, err)
+	assert.Nil(t, items)
+	assert.Equal(t, "INVALID_PROTOTYPE", err.(*cerr.ApplicationError).Code)
+}
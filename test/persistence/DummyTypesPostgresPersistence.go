@@ -0,0 +1,66 @@
+package test
+
+import (
+	"net"
+	"reflect"
+	"time"
+
+	persist "github.com/pip-services3-go/pip-services3-postgres-go/persistence"
+)
+
+type DummyTypes struct {
+	Id       string        `json:"id"`
+	Uuid     string        `json:"uuid" postgres:"type:UUID"`
+	Guid     [16]byte      `json:"guid"`
+	Created  time.Time     `json:"created"`
+	Updated  *time.Time    `json:"updated"`
+	Amount   float64       `json:"amount" postgres:"type:NUMERIC(12,2)"`
+	Price    string        `json:"price" postgres:"type:NUMERIC(12,2)"`
+	Tags     []string      `json:"tags" postgres:"type:TEXT[]"`
+	Counts   []int64       `json:"counts" postgres:"type:BIGINT[]"`
+	Address  net.IP        `json:"address"`
+	Network  string        `json:"network" postgres:"type:CIDR"`
+	Duration time.Duration `json:"duration"`
+	Data     []byte        `json:"data"`
+}
+
+type DummyTypesPostgresPersistence struct {
+	persist.IdentifiablePostgresPersistence
+}
+
+func NewDummyTypesPostgresPersistence() *DummyTypesPostgresPersistence {
+	proto := reflect.TypeOf(DummyTypes{})
+	c := &DummyTypesPostgresPersistence{}
+	c.IdentifiablePostgresPersistence = *persist.InheritIdentifiablePostgresPersistence(c, proto, "dummies_types")
+	return c
+}
+
+func (c *DummyTypesPostgresPersistence) DefineSchema() {
+	c.ClearSchema()
+	c.IdentifiablePostgresPersistence.DefineSchema()
+	c.EnsureTableFromPrototype()
+}
+
+type DummyNumbers struct {
+	Id    string  `json:"id"`
+	Small int8    `json:"small"`
+	Count uint16  `json:"count"`
+	Ratio float32 `json:"ratio"`
+}
+
+type DummyNumbersPostgresPersistence struct {
+	persist.IdentifiablePostgresPersistence
+}
+
+func NewDummyNumbersPostgresPersistence() *DummyNumbersPostgresPersistence {
+	proto := reflect.TypeOf(DummyNumbers{})
+	c := &DummyNumbersPostgresPersistence{}
+	c.IdentifiablePostgresPersistence = *persist.InheritIdentifiablePostgresPersistence(c, proto, "dummies_numbers")
+	return c
+}
+
+func (c *DummyNumbersPostgresPersistence) DefineSchema() {
+	c.ClearSchema()
+	c.IdentifiablePostgresPersistence.DefineSchema()
+	c.EnsureTableFromPrototype()
+}
@@ -0,0 +1,209 @@
+package test
+
+import (
+	"context"
+	"math/big"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgtype"
+	cconf "github.com/pip-services3-go/pip-services3-commons-go/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func newDummyTypes() DummyTypes {
+	updated := time.Date(2021, 5, 2, 0, 0, 0, 0, time.UTC)
+	return DummyTypes{
+		Id:       "1",
+		Uuid:     "3f2504e0-4f89-11d3-9a0c-0305e82c3301",
+		Guid:     [16]byte{0x3f, 0x25, 0x04, 0xe0, 0x4f, 0x89, 0x11, 0xd3, 0x9a, 0x0c, 0x03, 0x05, 0xe8, 0x2c, 0x33, 0x01},
+		Created:  time.Date(2021, 5, 1, 10, 30, 15, 123000, time.UTC),
+		Updated:  &updated,
+		Amount:   123.45,
+		Price:    "99.99",
+		Tags:     []string{"a", "b"},
+		Counts:   []int64{1, 2, 3},
+		Address:  net.ParseIP("192.168.0.1"),
+		Network:  "10.0.0.0/8",
+		Duration: 90 * time.Second,
+		Data:     []byte{1, 2, 3},
+	}
+}
+
+func TestDummyTypesConversion(t *testing.T) {
+	persistence := NewDummyTypesPostgresPersistence()
+	expected := newDummyTypes()
+
+	// Values as they are decoded by the driver
+	var tags pgtype.TextArray
+	tags.Set([]string{"a", "b"})
+	var counts pgtype.Int8Array
+	counts.Set([]int64{1, 2, 3})
+	_, network, _ := net.ParseCIDR("10.0.0.0/8")
+	address := &net.IPNet{IP: net.ParseIP("192.168.0.1").To4(), Mask: net.CIDRMask(32, 32)}
+
+	rows := &testRows{
+		names: []string{"id", "uuid", "guid", "created", "updated", "amount", "price",
+			"tags", "counts", "address", "network", "duration", "data"},
+		values: []interface{}{
+			"1",
+			expected.Guid,
+			expected.Guid,
+			expected.Created,
+			*expected.Updated,
+			pgtype.Numeric{Int: big.NewInt(12345), Exp: -2, Status: pgtype.Present},
+			pgtype.Numeric{Int: big.NewInt(9999), Exp: -2, Status: pgtype.Present},
+			tags,
+			counts,
+			address,
+			network,
+			pgtype.Interval{Microseconds: 90000000, Status: pgtype.Present},
+			[]byte{1, 2, 3},
+		},
+	}
+
+	item, ok := persistence.ConvertToPublic(rows).(DummyTypes)
+	assert.True(t, ok)
+
+	t.Run("UUID", func(t *testing.T) {
+		assert.Equal(t, expected.Uuid, item.Uuid)
+		assert.Equal(t, expected.Guid, item.Guid)
+	})
+	t.Run("Time", func(t *testing.T) {
+		assert.True(t, expected.Created.Equal(item.Created))
+		assert.NotNil(t, item.Updated)
+		assert.True(t, expected.Updated.Equal(*item.Updated))
+	})
+	t.Run("Numeric", func(t *testing.T) {
+		assert.Equal(t, expected.Amount, item.Amount)
+		assert.Equal(t, expected.Price, item.Price)
+	})
+	t.Run("Arrays", func(t *testing.T) {
+		assert.Equal(t, expected.Tags, item.Tags)
+		assert.Equal(t, expected.Counts, item.Counts)
+	})
+	t.Run("Inet", func(t *testing.T) {
+		assert.True(t, expected.Address.Equal(item.Address))
+		assert.Equal(t, expected.Network, item.Network)
+	})
+	t.Run("Interval", func(t *testing.T) {
+		assert.Equal(t, expected.Duration, item.Duration)
+	})
+	t.Run("Bytea", func(t *testing.T) {
+		assert.Equal(t, expected.Data, item.Data)
+	})
+
+	// Values are passed to the driver in native types
+	t.Run("Values", func(t *testing.T) {
+		columns := persistence.GenerateColumns(expected)
+		values := persistence.GenerateValues(columns, expected)
+		assert.Contains(t, values, expected.Created)
+		assert.Contains(t, values, expected.Guid)
+		assert.Contains(t, values, expected.Tags)
+		assert.Contains(t, values, expected.Address)
+		assert.Contains(t, values, expected.Duration)
+		assert.Contains(t, values, *expected.Updated)
+	})
+}
+
+func TestDummyTypesNumbers(t *testing.T) {
+	persistence := NewDummyNumbersPostgresPersistence()
+	names := []string{"id", "small", "count", "ratio"}
+
+	// Numbers that fit into fields are converted
+	rows := &testRows{names: names, values: []interface{}{"1", int64(100), int32(65535), float64(0.5)}}
+	item, ok := persistence.ConvertToPublic(rows).(DummyNumbers)
+	assert.True(t, ok)
+	assert.Equal(t, DummyNumbers{Id: "1", Small: 100, Count: 65535, Ratio: 0.5}, item)
+
+	// Numbers that overflow fields or have fractions are not truncated
+	rows = &testRows{names: names, values: []interface{}{"2", int64(300), float64(2.5), float64(0.25)}}
+	item, ok = persistence.ConvertToPublic(rows).(DummyNumbers)
+	assert.True(t, ok)
+	assert.Equal(t, DummyNumbers{Id: "2", Ratio: 0.25}, item)
+
+	rows = &testRows{names: names, values: []interface{}{"3", int64(1), int64(-1), float64(0)}}
+	item, ok = persistence.ConvertToPublic(rows).(DummyNumbers)
+	assert.True(t, ok)
+	assert.Equal(t, uint16(0), item.Count)
+}
+
+func TestDummyTypesPostgresPersistence(t *testing.T) {
+
+	postgresUri := os.Getenv("POSTGRES_URI")
+	postgresHost := os.Getenv("POSTGRES_HOST")
+	if postgresHost == "" {
+		postgresHost = "localhost"
+	}
+
+	postgresPort := os.Getenv("POSTGRES_PORT")
+	if postgresPort == "" {
+		postgresPort = "5432"
+	}
+
+	postgresDatabase := os.Getenv("POSTGRES_DB")
+	if postgresDatabase == "" {
+		postgresDatabase = "test"
+	}
+
+	postgresUser := os.Getenv("POSTGRES_USER")
+	if postgresUser == "" {
+		postgresUser = "postgres"
+	}
+	postgresPassword := os.Getenv("POSTGRES_PASSWORD")
+	if postgresPassword == "" {
+		postgresPassword = "postgres#"
+	}
+
+	if postgresUri == "" && postgresHost == "" {
+		panic("Connection params not set")
+	}
+
+	dbConfig := cconf.NewConfigParamsFromTuples(
+		"connection.uri", postgresUri,
+		"connection.host", postgresHost,
+		"connection.port", postgresPort,
+		"connection.database", postgresDatabase,
+		"credential.username", postgresUser,
+		"credential.password", postgresPassword,
+	)
+
+	persistence := NewDummyTypesPostgresPersistence()
+	persistence.Configure(dbConfig)
+
+	opnErr := persistence.Open("")
+	if opnErr != nil {
+		t.Error("Error opened persistence", opnErr)
+		return
+	}
+	defer persistence.Close("")
+
+	expected := newDummyTypes()
+	created, err := persistence.Create("", expected)
+	assert.Nil(t, err)
+	assert.NotNil(t, created)
+
+	result, err := persistence.GetOneById("", expected.Id)
+	assert.Nil(t, err)
+	item, ok := result.(DummyTypes)
+	assert.True(t, ok)
+
+	assert.Equal(t, expected.Uuid, item.Uuid)
+	assert.Equal(t, expected.Guid, item.Guid)
+	assert.True(t, expected.Created.Equal(item.Created))
+	assert.True(t, expected.Updated.Equal(*item.Updated))
+	assert.Equal(t, expected.Amount, item.Amount)
+	assert.Equal(t, expected.Price, item.Price)
+	assert.Equal(t, expected.Tags, item.Tags)
+	assert.Equal(t, expected.Counts, item.Counts)
+	assert.True(t, expected.Address.Equal(item.Address))
+	assert.Equal(t, expected.Network, item.Network)
+	assert.Equal(t, expected.Duration, item.Duration)
+	assert.Equal(t, expected.Data, item.Data)
+
+	rows, err := persistence.Query(context.Background(), "", "DROP TABLE "+persistence.QuotedTableName())
+	assert.Nil(t, err)
+	rows.Close()
+}
@@ -0,0 +1,60 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v4"
+	cerr "github.com/pip-services3-go/pip-services3-commons-go/errors"
+	persist "github.com/pip-services3-go/pip-services3-postgres-go/persistence"
+	"github.com/stretchr/testify/assert"
+)
+
+var errTestQuery = errors.New("query is not supported")
+
+// Transaction that fails queries and completes without a database
+type testTx struct {
+	pgx.Tx
+}
+
+func (c *testTx) Rollback(ctx context.Context) error {
+	return nil
+}
+
+func (c *testTx) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return nil, errTestQuery
+}
+
+func TestPostgresTransactionCheck(t *testing.T) {
+	persistence := NewDummyPostgresPersistence()
+
+	readCode := func(tx pgx.Tx) string {
+		item, err := persistence.IdentifiablePostgresPersistence.GetOneByIdLocked("123", tx, "1", nil)
+		assert.Nil(t, item)
+		if err == errTestQuery {
+			return ""
+		}
+		appErr, ok := err.(*cerr.ApplicationError)
+		assert.True(t, ok)
+		assert.Equal(t, cerr.InvalidState, appErr.Category)
+		return appErr.Code
+	}
+
+	var nilTx *persist.PostgresTransaction
+	var nilTestTx *testTx
+	assert.Equal(t, "NO_TRANSACTION", readCode(nil))
+	assert.Equal(t, "NO_TRANSACTION", readCode(nilTx))
+	assert.Equal(t, "NO_TRANSACTION", readCode(nilTestTx))
+	assert.Equal(t, "NO_TRANSACTION", readCode(&persist.PostgresTransaction{}))
+
+	// Queries are sent within open transactions
+	tx := &persist.PostgresTransaction{Tx: &testTx{}}
+	assert.Equal(t, "", readCode(tx))
+	assert.Equal(t, "", readCode(&testTx{}))
+
+	// Completed transaction can be rolled back again, but can not be used
+	assert.Nil(t, tx.Rollback(context.Background()))
+	assert.Nil(t, tx.Rollback(context.Background()))
+	assert.Equal(t, "TRANSACTION_CLOSED", readCode(tx))
+}
@@ -0,0 +1,78 @@
+package test
+
+import (
+	"os"
+	"testing"
+
+	cconf "github.com/pip-services3-go/pip-services3-commons-go/config"
+	persist "github.com/pip-services3-go/pip-services3-postgres-go/persistence"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresUpdateOperations(t *testing.T) {
+
+	postgresUri := os.Getenv("POSTGRES_URI")
+	postgresHost := os.Getenv("POSTGRES_HOST")
+	if postgresHost == "" {
+		postgresHost = "localhost"
+	}
+
+	postgresPort := os.Getenv("POSTGRES_PORT")
+	if postgresPort == "" {
+		postgresPort = "5432"
+	}
+
+	postgresDatabase := os.Getenv("POSTGRES_DB")
+	if postgresDatabase == "" {
+		postgresDatabase = "test"
+	}
+
+	postgresUser := os.Getenv("POSTGRES_USER")
+	if postgresUser == "" {
+		postgresUser = "postgres"
+	}
+	postgresPassword := os.Getenv("POSTGRES_PASSWORD")
+	if postgresPassword == "" {
+		postgresPassword = "postgres#"
+	}
+
+	if postgresUri == "" && postgresHost == "" {
+		panic("Connection params not set")
+	}
+
+	dbConfig := cconf.NewConfigParamsFromTuples(
+		"connection.uri", postgresUri,
+		"connection.host", postgresHost,
+		"connection.port", postgresPort,
+		"connection.database", postgresDatabase,
+		"credential.username", postgresUser,
+		"credential.password", postgresPassword,
+	)
+
+	persistence := NewDummyTablePostgresPersistence()
+	persistence.Configure(dbConfig)
+
+	opnErr := persistence.Open("")
+	if opnErr != nil {
+		t.Error("Error opened persistence", opnErr)
+		return
+	}
+	defer persistence.Close("")
+	defer persistence.Clear("")
+
+	// SQL of chained operations on the same path grows linearly,
+	// otherwise a statement with 40 operations would not fit in memory
+	t.Run("PostgresUpdateOperations:Chained", func(t *testing.T) {
+		_, err := persistence.Create("", DummyTable{Id: "chained", Key: "Key chained"})
+		assert.Nil(t, err)
+
+		operations := persist.NewPostgresUpdateOperations()
+		for index := 0; index < 40; index++ {
+			operations.IncrementPath("params", []string{"stats", "views", "total"}, 1)
+		}
+		result, err := persistence.UpdateByOperations("", "chained", operations)
+		assert.Nil(t, err)
+		dummy := result.(DummyTable)
+		assert.Equal(t, map[string]interface{}{"views": map[string]interface{}{"total": float64(40)}}, dummy.Params["stats"])
+	})
+}